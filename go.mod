@@ -1,23 +1,30 @@
 module github.com/brendoncarroll/webfs
 
-go 1.18
+go 1.20
 
 require (
 	github.com/blobcache/blobcache v0.0.0-20220615224329-ce25fe33118b
 	github.com/brendoncarroll/go-state v0.0.0-20220617134034-2613fe050888
+	github.com/brendoncarroll/go-tai64 v0.0.0-20220527232055-eab29bd93d59
+	github.com/go-git/go-billy/v5 v5.6.0
 	github.com/gotvc/got v0.0.3-0.20220618220735-aa388cfe7f66
+	github.com/hanwen/go-fuse/v2 v2.1.0
 	github.com/ipfs/go-ipfs-api v0.0.1
+	github.com/ipfs/go-ipfs-files v0.0.1
 	github.com/multiformats/go-multihash v0.0.1
 	github.com/sirupsen/logrus v1.7.0
 	github.com/spf13/cobra v0.0.5
-	github.com/stretchr/testify v1.7.0
-	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
+	github.com/stretchr/testify v1.9.0
+	github.com/willscott/go-nfs v0.0.3
+	github.com/willscott/go-nfs-client v0.0.0-20240104095149-b44639837b00
+	golang.org/x/crypto v0.25.0
+	golang.org/x/net v0.27.0
+	google.golang.org/protobuf v1.27.1
 )
 
 require (
 	github.com/DataDog/zstd v1.4.1 // indirect
 	github.com/brendoncarroll/go-p2p v0.0.0-20220617145626-749dd26b09b0 // indirect
-	github.com/brendoncarroll/go-tai64 v0.0.0-20220527232055-eab29bd93d59 // indirect
 	github.com/btcsuite/btcd v0.0.0-20190213025234-306aecffea32 // indirect
 	github.com/cespare/xxhash v1.1.0 // indirect
 	github.com/chmduquesne/rollinghash v0.0.0-20180912150627-a60f8e7142b5 // indirect
@@ -34,7 +41,6 @@ require (
 	github.com/hashicorp/golang-lru v0.5.1 // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/inet256/inet256 v0.0.5 // indirect
-	github.com/ipfs/go-ipfs-files v0.0.1 // indirect
 	github.com/klauspost/cpuid v1.3.1 // indirect
 	github.com/libp2p/go-flow-metrics v0.0.1 // indirect
 	github.com/libp2p/go-libp2p-crypto v0.0.1 // indirect
@@ -51,16 +57,15 @@ require (
 	github.com/multiformats/go-multiaddr-net v0.0.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/rasky/go-xdr v0.0.0-20170124162913-1a41d1a06c93 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/whyrusleeping/tar-utils v0.0.0-20180509141711-8c6c8ba81d5c // indirect
-	golang.org/x/exp v0.0.0-20220518171630-0b5c67f07fdf // indirect
-	golang.org/x/net v0.0.0-20211015210444-4f30a5c0130f // indirect
-	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
-	golang.org/x/sys v0.0.0-20211019181941-9d821ace8654 // indirect
-	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/exp v0.0.0-20240719175910-8a7402abbf56 // indirect
+	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/sys v0.24.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
 	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 	google.golang.org/grpc v1.33.2 // indirect
-	google.golang.org/protobuf v1.27.1 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 	lukechampine.com/blake3 v1.1.5 // indirect
 )
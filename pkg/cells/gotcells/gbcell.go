@@ -1,21 +1,33 @@
 package gotcells
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 
 	"github.com/brendoncarroll/go-state/cadata"
 	"github.com/brendoncarroll/go-state/cells"
+	"github.com/brendoncarroll/go-tai64"
+	"github.com/gotvc/got/pkg/gotfs"
 	"github.com/gotvc/got/pkg/gotvc"
 )
 
+// Creator is recorded as the creator of the snapshots made by a BranchCell.
+const Creator = "webfs"
+
+// BranchCell presents the head of a Got branch as a cell containing a JSON encoded gotfs.Root.
+// The inner cell holds the JSON encoded gotvc.Snapshot at the head of the branch.
+// Each successful CAS creates a new snapshot, with the previous head as its parent,
+// so every change made through the cell is kept in the branch's history.
+// Emptying the cell creates a snapshot with an empty root, see IsEmpty.
 type BranchCell struct {
 	inner   cells.Cell
 	gotvc   *gotvc.Operator
 	vcStore cadata.Store
 }
 
+// NewBranch returns a BranchCell for the branch with its head in inner.
+// The parents of new snapshots are posted to vcStore.
 func NewBranch(inner cells.Cell, vcop *gotvc.Operator, vcStore cadata.Store) *BranchCell {
 	return &BranchCell{
 		inner:   inner,
@@ -25,28 +37,123 @@ func NewBranch(inner cells.Cell, vcop *gotvc.Operator, vcStore cadata.Store) *Br
 }
 
 func (c *BranchCell) Read(ctx context.Context, buf []byte) (int, error) {
-	n, err := c.inner.Read(ctx, buf)
+	snap, err := c.Head(ctx)
 	if err != nil {
 		return 0, err
 	}
-	if n == 0 {
-		return n, nil
+	return copyRoot(buf, snap)
+}
+
+func (c *BranchCell) CAS(ctx context.Context, actual, prev, next []byte) (bool, int, error) {
+	innerPrev := make([]byte, c.inner.MaxSize())
+	n, err := c.inner.Read(ctx, innerPrev)
+	if err != nil {
+		return false, 0, err
 	}
-	var snap gotvc.Snap
-	if err := json.Unmarshal(buf[:n], &snap); err != nil {
-		return 0, err
+	innerPrev = innerPrev[:n]
+	head, err := parseSnapshot(innerPrev)
+	if err != nil {
+		return false, 0, err
 	}
-	data, err := json.Marshal(snap.Root)
+	cur := make([]byte, c.MaxSize())
+	n, err = copyRoot(cur, head)
 	if err != nil {
-		return 0, err
+		return false, 0, err
 	}
-	return copy(buf, data), nil
-}
-
-func (c *BranchCell) CAS(ctx context.Context, actual, prev, next []byte) (bool, int, error) {
-	return false, 0, errors.New("writing to got branches not yet supported")
+	cur = cur[:n]
+	if !bytes.Equal(cur, prev) {
+		return false, copy(actual, cur), nil
+	}
+	if bytes.Equal(cur, next) {
+		return true, copy(actual, next), nil
+	}
+	// an empty cell is recorded as a snapshot with the zero root, so the history before it is kept.
+	var root gotfs.Root
+	if len(next) > 0 {
+		if err := json.Unmarshal(next, &root); err != nil {
+			return false, 0, err
+		}
+	}
+	var parents []gotvc.Snapshot
+	if head != nil {
+		parents = append(parents, *head)
+	}
+	now := tai64.Now().TAI64()
+	snap, err := c.gotvc.NewSnapshot(ctx, c.vcStore, parents, root, gotvc.SnapInfo{
+		CreatedAt:  now,
+		AuthoredAt: now,
+		Creator:    Creator,
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	innerNext, err := json.Marshal(snap)
+	if err != nil {
+		return false, 0, err
+	}
+	innerActual := make([]byte, c.inner.MaxSize())
+	swapped, n, err := c.inner.CAS(ctx, innerActual, innerPrev, innerNext)
+	if err != nil {
+		return false, 0, err
+	}
+	if swapped {
+		return true, copy(actual, next), nil
+	}
+	// someone else changed the branch, report the root at their head.
+	head, err = parseSnapshot(innerActual[:n])
+	if err != nil {
+		return false, 0, err
+	}
+	n, err = copyRoot(actual, head)
+	return false, n, err
 }
 
 func (c *BranchCell) MaxSize() int {
 	return 1 << 10
 }
+
+// Head returns the snapshot at the head of the branch, or nil if the branch is empty.
+func (c *BranchCell) Head(ctx context.Context) (*gotvc.Snapshot, error) {
+	buf := make([]byte, c.inner.MaxSize())
+	n, err := c.inner.Read(ctx, buf)
+	if err != nil {
+		return nil, err
+	}
+	return parseSnapshot(buf[:n])
+}
+
+// VCStore returns the store holding the snapshots in the branch's history.
+func (c *BranchCell) VCStore() cadata.Store {
+	return c.vcStore
+}
+
+// IsEmpty returns true if snap records that the cell was emptied, its root is not a valid gotfs.Root.
+func IsEmpty(snap gotvc.Snapshot) bool {
+	return snap.Root.Ref.CID.IsZero() && snap.Root.Depth == 0 && len(snap.Root.First) == 0
+}
+
+func parseSnapshot(data []byte) (*gotvc.Snapshot, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var snap gotvc.Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// copyRoot copies the JSON encoded root of snap into buf.
+func copyRoot(buf []byte, snap *gotvc.Snapshot) (int, error) {
+	if snap == nil || IsEmpty(*snap) {
+		return 0, nil
+	}
+	data, err := json.Marshal(snap.Root)
+	if err != nil {
+		return 0, err
+	}
+	if len(buf) < len(data) {
+		return 0, cells.ErrTooLarge{}
+	}
+	return copy(buf, data), nil
+}
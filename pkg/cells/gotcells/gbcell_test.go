@@ -0,0 +1,108 @@
+package gotcells
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/brendoncarroll/go-state/cells"
+	"github.com/gotvc/got/pkg/gotfs"
+	"github.com/gotvc/got/pkg/gotvc"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestBranchCell(t *testing.T) {
+	ctx := context.Background()
+	s := cadata.NewMem(func(x []byte) cadata.ID { return blake2b.Sum256(x) }, gotfs.DefaultMaxBlobSize)
+	vcop := gotvc.NewOperator()
+	c := NewBranch(cells.NewMem(1<<16), &vcop, s)
+
+	fsop := gotfs.NewOperator()
+	var roots [][]byte
+	for _, p := range []string{"a", "b", "c"} {
+		root, err := fsop.NewEmpty(ctx, s)
+		require.NoError(t, err)
+		root, err = fsop.Mkdir(ctx, s, *root, p)
+		require.NoError(t, err)
+		data, err := json.Marshal(root)
+		require.NoError(t, err)
+		require.NoError(t, cells.Apply(ctx, c, func([]byte) ([]byte, error) {
+			return data, nil
+		}))
+		roots = append(roots, data)
+		actual, err := cells.GetBytes(ctx, c)
+		require.NoError(t, err)
+		require.Equal(t, data, actual)
+	}
+
+	// walk the history back from the head.
+	head, err := c.Head(ctx)
+	require.NoError(t, err)
+	for i := len(roots) - 1; i >= 0; i-- {
+		require.Equal(t, uint64(i), head.N)
+		data, err := json.Marshal(head.Root)
+		require.NoError(t, err)
+		require.Equal(t, roots[i], data)
+		if i == 0 {
+			require.Len(t, head.Parents, 0)
+			break
+		}
+		require.Len(t, head.Parents, 1)
+		head, err = vcop.GetSnapshot(ctx, s, head.Parents[0])
+		require.NoError(t, err)
+	}
+
+	// a CAS with the wrong previous value fails, and reports the current root.
+	actual := make([]byte, c.MaxSize())
+	swapped, n, err := c.CAS(ctx, actual, roots[0], roots[1])
+	require.NoError(t, err)
+	require.False(t, swapped)
+	require.Equal(t, roots[2], actual[:n])
+}
+
+func TestBranchCellEmpty(t *testing.T) {
+	ctx := context.Background()
+	s := cadata.NewMem(func(x []byte) cadata.ID { return blake2b.Sum256(x) }, gotfs.DefaultMaxBlobSize)
+	vcop := gotvc.NewOperator()
+	c := NewBranch(cells.NewMem(1<<16), &vcop, s)
+
+	fsop := gotfs.NewOperator()
+	root, err := fsop.NewEmpty(ctx, s)
+	require.NoError(t, err)
+	data, err := json.Marshal(root)
+	require.NoError(t, err)
+	actual := make([]byte, c.MaxSize())
+	swapped, _, err := c.CAS(ctx, actual, nil, data)
+	require.NoError(t, err)
+	require.True(t, swapped)
+
+	// emptying the cell keeps the history before it.
+	swapped, n, err := c.CAS(ctx, actual, data, nil)
+	require.NoError(t, err)
+	require.True(t, swapped)
+	require.Equal(t, 0, n)
+	current, err := cells.GetBytes(ctx, c)
+	require.NoError(t, err)
+	require.Empty(t, current)
+	head, err := c.Head(ctx)
+	require.NoError(t, err)
+	require.True(t, IsEmpty(*head))
+	require.Equal(t, uint64(1), head.N)
+	require.Len(t, head.Parents, 1)
+	parent, err := vcop.GetSnapshot(ctx, s, head.Parents[0])
+	require.NoError(t, err)
+	require.False(t, IsEmpty(*parent))
+	parentData, err := json.Marshal(parent.Root)
+	require.NoError(t, err)
+	require.Equal(t, data, parentData)
+
+	// the empty cell can be written again.
+	swapped, _, err = c.CAS(ctx, actual, nil, data)
+	require.NoError(t, err)
+	require.True(t, swapped)
+	head, err = c.Head(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), head.N)
+}
@@ -0,0 +1,38 @@
+package literalcell
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/brendoncarroll/go-state/cells"
+)
+
+// ErrReadOnly is returned by CAS, literal cells can never be changed.
+var ErrReadOnly = errors.New("literalcell: cell is read-only")
+
+var _ cells.Cell = &Cell{}
+
+// Cell is a read-only cell which always contains the same data.
+type Cell struct {
+	data []byte
+}
+
+func New(data []byte) *Cell {
+	return &Cell{data: append([]byte{}, data...)}
+}
+
+func (c *Cell) Read(ctx context.Context, buf []byte) (int, error) {
+	if len(buf) < len(c.data) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(buf, c.data), nil
+}
+
+func (c *Cell) CAS(ctx context.Context, actual, prev, next []byte) (bool, int, error) {
+	return false, 0, ErrReadOnly
+}
+
+func (c *Cell) MaxSize() int {
+	return len(c.data)
+}
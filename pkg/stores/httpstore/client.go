@@ -0,0 +1,189 @@
+package httpstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/brendoncarroll/go-state/cadata"
+)
+
+var _ cadata.Store = &Client{}
+
+// Client is a cadata.Store backed by an HTTP endpoint implementing the protocol served by Server.
+// All data received from the server is checked against its ID.
+type Client struct {
+	endpoint string
+	hf       cadata.HashFunc
+	maxSize  int
+	headers  map[string]string
+	hc       *http.Client
+}
+
+// New returns a Client for the store at endpoint.
+// hf and maxSize must match the store being served, headers are added to every request.
+func New(endpoint string, hf cadata.HashFunc, maxSize int, headers map[string]string) *Client {
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+	return &Client{
+		endpoint: endpoint,
+		hf:       hf,
+		maxSize:  maxSize,
+		headers:  headers,
+		hc:       http.DefaultClient,
+	}
+}
+
+func (c *Client) Post(ctx context.Context, data []byte) (cadata.ID, error) {
+	if len(data) > c.maxSize {
+		return cadata.ID{}, cadata.ErrTooLarge
+	}
+	resp, err := c.do(ctx, http.MethodPost, c.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return cadata.ID{}, err
+	}
+	defer closeBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return cadata.ID{}, errorFromRes(resp)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return cadata.ID{}, err
+	}
+	var id cadata.ID
+	if err := id.UnmarshalBase64(bytes.TrimSpace(body)); err != nil {
+		return cadata.ID{}, err
+	}
+	if id != c.hf(data) {
+		return cadata.ID{}, fmt.Errorf("httpstore: server returned wrong ID for data")
+	}
+	return id, nil
+}
+
+func (c *Client) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.blobURL(id), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer closeBody(resp)
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return 0, cadata.ErrNotFound
+	default:
+		return 0, errorFromRes(resp)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(c.maxSize)+1))
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > len(buf) {
+		return 0, io.ErrShortBuffer
+	}
+	if err := cadata.Check(c.hf, id, data); err != nil {
+		return 0, err
+	}
+	return copy(buf, data), nil
+}
+
+func (c *Client) Exists(ctx context.Context, id cadata.ID) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, c.blobURL(id), nil)
+	if err != nil {
+		return false, err
+	}
+	defer closeBody(resp)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, errorFromRes(resp)
+	}
+}
+
+func (c *Client) List(ctx context.Context, span cadata.Span, ids []cadata.ID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	q := url.Values{}
+	q.Set(queryBegin, cadata.BeginFromSpan(span).String())
+	if end, ok := cadata.EndFromSpan(span); ok {
+		q.Set(queryEnd, end.String())
+	}
+	q.Set(queryLimit, strconv.Itoa(len(ids)))
+	resp, err := c.do(ctx, http.MethodGet, c.endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer closeBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return 0, errorFromRes(resp)
+	}
+	var listed []cadata.ID
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		return 0, err
+	}
+	if len(listed) > len(ids) {
+		return 0, fmt.Errorf("httpstore: server listed %d IDs, limit was %d", len(listed), len(ids))
+	}
+	return copy(ids, listed), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id cadata.ID) error {
+	resp, err := c.do(ctx, http.MethodDelete, c.blobURL(id), nil)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return errorFromRes(resp)
+	}
+	return nil
+}
+
+func (c *Client) Hash(x []byte) cadata.ID {
+	return c.hf(x)
+}
+
+func (c *Client) MaxSize() int {
+	return c.maxSize
+}
+
+func (c *Client) blobURL(id cadata.ID) string {
+	return c.endpoint + id.String()
+}
+
+func (c *Client) do(ctx context.Context, method, u string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	return c.hc.Do(req)
+}
+
+func closeBody(resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		log.Println(err)
+	}
+}
+
+func errorFromRes(r *http.Response) error {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1024))
+	if err != nil {
+		log.Println(err)
+	}
+	return fmt.Errorf("httpstore: %s: %s", r.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,67 @@
+package httpstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/brendoncarroll/go-state/cadata/storetest"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+const testMaxSize = 1 << 16
+
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func(t testing.TB) cadata.Store {
+		return newTestClient(t, NewServer(newTestStore()), nil)
+	})
+}
+
+func TestHeaders(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer(newTestStore())
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		srv.ServeHTTP(w, r)
+	})
+	_, err := newTestClient(t, h, nil).Post(ctx, []byte("hello"))
+	require.Error(t, err)
+	c := newTestClient(t, h, map[string]string{"Authorization": "Bearer secret"})
+	_, err = c.Post(ctx, []byte("hello"))
+	require.NoError(t, err)
+}
+
+func TestBadData(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore()
+	c := newTestClient(t, NewServer(s), nil)
+	id, err := c.Post(ctx, []byte("hello"))
+	require.NoError(t, err)
+
+	// a server which returns the wrong data for every ID.
+	liar := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("goodbye"))
+	})
+	_, err = cadata.GetBytes(ctx, newTestClient(t, liar, nil), id)
+	require.ErrorIs(t, err, cadata.ErrBadData)
+}
+
+func newTestStore() cadata.Store {
+	return cadata.NewMem(testHash, testMaxSize)
+}
+
+func newTestClient(t testing.TB, h http.Handler, headers map[string]string) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return New(srv.URL, testHash, testMaxSize, headers)
+}
+
+func testHash(x []byte) cadata.ID {
+	return blake2b.Sum256(x)
+}
@@ -0,0 +1,175 @@
+package httpstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/brendoncarroll/go-state"
+	"github.com/brendoncarroll/go-state/cadata"
+)
+
+const (
+	queryBegin = "begin"
+	queryEnd   = "end"
+	queryLimit = "limit"
+
+	// maxListLimit is the most IDs which will be returned by a single list request.
+	maxListLimit = 1024
+)
+
+// Server serves a cadata.Store over HTTP.
+//
+//	POST /        stores the body, and responds with its ID
+//	GET  /<id>    responds with the blob
+//	HEAD /<id>    responds 200 if the blob exists, 404 if it does not
+//	DELETE /<id>  removes the blob
+//	GET  /?begin=<id>&end=<id>&limit=<n>  responds with a JSON list of IDs in [begin, end)
+//
+// IDs in paths and queries are encoded with cadata.Base64Alphabet.
+type Server struct {
+	store cadata.Store
+}
+
+// NewServer returns a Server for store.
+func NewServer(store cadata.Store) *Server {
+	return &Server{store: store}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, "/")
+	if p == "" {
+		switch r.Method {
+		case http.MethodPost:
+			s.post(w, r)
+		case http.MethodGet:
+			s.list(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	var id cadata.ID
+	if err := id.UnmarshalBase64([]byte(p)); err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.get(w, r, id)
+	case http.MethodHead:
+		s.head(w, r, id)
+	case http.MethodDelete:
+		s.delete(w, r, id)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) post(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(s.store.MaxSize())+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) > s.store.MaxSize() {
+		http.Error(w, cadata.ErrTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	id, err := s.store.Post(r.Context(), data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, id.String())
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, id cadata.ID) {
+	buf := make([]byte, s.store.MaxSize())
+	n, err := s.store.Get(r.Context(), id, buf)
+	if cadata.IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(n))
+	w.Write(buf[:n])
+}
+
+func (s *Server) head(w http.ResponseWriter, r *http.Request, id cadata.ID) {
+	yes, err := cadata.Exists(r.Context(), s.store, id)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !yes {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, id cadata.ID) {
+	if err := s.store.Delete(r.Context(), id); err != nil && !cadata.IsNotFound(err) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	span, limit, err := parseListQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ids := make([]cadata.ID, limit)
+	n, err := s.store.List(r.Context(), span, ids)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ids[:n])
+}
+
+func parseListQuery(r *http.Request) (cadata.Span, int, error) {
+	q := r.URL.Query()
+	span := state.TotalSpan[cadata.ID]()
+	if x := q.Get(queryBegin); x != "" {
+		var begin cadata.ID
+		if err := begin.UnmarshalBase64([]byte(x)); err != nil {
+			return span, 0, err
+		}
+		span = span.WithLowerIncl(begin)
+	}
+	if x := q.Get(queryEnd); x != "" {
+		var end cadata.ID
+		if err := end.UnmarshalBase64([]byte(x)); err != nil {
+			return span, 0, err
+		}
+		span = span.WithUpperExcl(end)
+	}
+	limit := maxListLimit
+	if x := q.Get(queryLimit); x != "" {
+		l, err := strconv.Atoi(x)
+		if err != nil {
+			return span, 0, err
+		}
+		if l < 1 {
+			return span, 0, errors.New("limit must be positive")
+		}
+		if l < limit {
+			limit = l
+		}
+	}
+	return span, limit, nil
+}
@@ -0,0 +1,66 @@
+package ipfsstore
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/multiformats/go-multihash"
+)
+
+const (
+	cidVersion1 = 1
+	// codecRaw is the multicodec for raw binary blocks.
+	codecRaw = 0x55
+	// mhBlake2b256 is the multihash code for blake2b-256.
+	mhBlake2b256 = multihash.BLAKE2B_MIN + DefaultMHLen - 1
+	// multibaseBase32 is the multibase prefix for lowercase, unpadded base32.
+	multibaseBase32 = 'b'
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// encodeCID returns the CIDv1 string for the raw block with ID id.
+func encodeCID(id cadata.ID) string {
+	mh, err := multihash.Encode(id[:], mhBlake2b256)
+	if err != nil {
+		// the code and length are constants
+		panic(err)
+	}
+	buf := make([]byte, 2*binary.MaxVarintLen64, 2*binary.MaxVarintLen64+len(mh))
+	n := binary.PutUvarint(buf, cidVersion1)
+	n += binary.PutUvarint(buf[n:], codecRaw)
+	buf = append(buf[:n], mh...)
+	return string(multibaseBase32) + strings.ToLower(b32.EncodeToString(buf))
+}
+
+// decodeCID returns the ID of the block referenced by a base32 CIDv1 string.
+// Only CIDs using a blake2b-256 multihash can be decoded, since those are the only ones the store produces.
+func decodeCID(x string) (cadata.ID, error) {
+	if len(x) == 0 || x[0] != multibaseBase32 {
+		return cadata.ID{}, fmt.Errorf("unsupported CID %q", x)
+	}
+	buf, err := b32.DecodeString(strings.ToUpper(x[1:]))
+	if err != nil {
+		return cadata.ID{}, err
+	}
+	version, n := binary.Uvarint(buf)
+	if n <= 0 || version != cidVersion1 {
+		return cadata.ID{}, fmt.Errorf("unsupported CID %q", x)
+	}
+	buf = buf[n:]
+	if _, n = binary.Uvarint(buf); n <= 0 {
+		return cadata.ID{}, errors.New("invalid CID codec")
+	}
+	dmh, err := multihash.Decode(buf[n:])
+	if err != nil {
+		return cadata.ID{}, err
+	}
+	if dmh.Code != mhBlake2b256 || len(dmh.Digest) != DefaultMHLen {
+		return cadata.ID{}, fmt.Errorf("CID %q does not use %s", x, DefaultMHType)
+	}
+	return cadata.IDFromBytes(dmh.Digest), nil
+}
@@ -3,10 +3,13 @@ package ipfsstore
 import (
 	"context"
 	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
 
 	"github.com/brendoncarroll/go-state/cadata"
 	ipfsapi "github.com/ipfs/go-ipfs-api"
-	"github.com/multiformats/go-multihash"
+	files "github.com/ipfs/go-ipfs-files"
 	"golang.org/x/crypto/blake2b"
 )
 
@@ -21,6 +24,11 @@ const (
 	CloudflareURL      = "https://cloudflare-ipfs.com"
 )
 
+var _ cadata.Store = &ipfsClient{}
+
+// ipfsClient stores blobs as raw IPFS blocks, addressed by their blake2b-256 hash.
+// Every blob posted is directly pinned, and the set of direct pins is what List returns,
+// so blobs are not garbage collected by the node until they are deleted from the store.
 type ipfsClient struct {
 	client *ipfsapi.Shell
 }
@@ -30,39 +38,104 @@ func New(client *ipfsapi.Shell) cadata.Store {
 }
 
 func (s *ipfsClient) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error) {
-	data, err := s.client.BlockGet("")
+	resp, err := s.client.Request("block/get", encodeCID(id)).Send(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Close()
+	if resp.Error != nil {
+		return 0, convertError(resp.Error)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Output, MaxBlobSize+1))
 	if err != nil {
 		return 0, err
 	}
 	if len(buf) < len(data) {
 		return 0, io.ErrShortBuffer
 	}
-	return copy(buf, data), nil
+	n := copy(buf, data)
+	if err := cadata.Check(s.Hash, id, buf[:n]); err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
 func (s *ipfsClient) Post(ctx context.Context, data []byte) (cadata.ID, error) {
-	var (
-		format = ""
-		mhtype = DefaultMHType
-		mhlen  = DefaultMHLen
-	)
-	k, err := s.client.BlockPut(data, format, mhtype, mhlen)
-	if err != nil {
+	if len(data) > MaxBlobSize {
+		return cadata.ID{}, cadata.ErrTooLarge
+	}
+	id := s.Hash(data)
+	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", files.NewBytesFile(data))})
+	var out struct {
+		Key string
+	}
+	if err := s.client.Request("block/put").
+		Option("format", "raw").
+		Option("mhtype", DefaultMHType).
+		Option("mhlen", DefaultMHLen).
+		Body(files.NewMultiFileReader(slf, true)).
+		Exec(ctx, &out); err != nil {
 		return cadata.ID{}, err
 	}
-	mh, err := multihash.Decode([]byte(k))
+	id2, err := decodeCID(out.Key)
 	if err != nil {
 		return cadata.ID{}, err
 	}
-	return cadata.IDFromBytes(mh.Digest), nil
+	if id2 != id {
+		return cadata.ID{}, cadata.ErrBadData
+	}
+	if err := s.client.Request("pin/add", out.Key).
+		Option("recursive", false).
+		Exec(ctx, nil); err != nil {
+		return cadata.ID{}, err
+	}
+	return id, nil
 }
 
+// List lists the blobs which are directly pinned on the node.
 func (s *ipfsClient) List(ctx context.Context, span cadata.Span, ids []cadata.ID) (int, error) {
-	panic("not implemented")
+	all, err := s.listPins(ctx)
+	if err != nil {
+		return 0, err
+	}
+	begin := cadata.BeginFromSpan(span)
+	i := sort.Search(len(all), func(i int) bool {
+		return all[i].Compare(begin) >= 0
+	})
+	var n int
+	for ; i < len(all) && n < len(ids); i++ {
+		if !span.Contains(all[i], func(a, b cadata.ID) int { return a.Compare(b) }) {
+			break
+		}
+		ids[n] = all[i]
+		n++
+	}
+	return n, nil
+}
+
+// Exists returns true if id is directly pinned on the node.
+func (s *ipfsClient) Exists(ctx context.Context, id cadata.ID) (bool, error) {
+	err := s.client.Request("pin/ls", encodeCID(id)).
+		Option("type", ipfsapi.DirectPin).
+		Exec(ctx, nil)
+	if isNotPinned(err) {
+		return false, nil
+	}
+	return err == nil, err
 }
 
+// Delete unpins the block for id and removes it from the node.
 func (s *ipfsClient) Delete(ctx context.Context, id cadata.ID) error {
-	panic("not implemented")
+	cid := encodeCID(id)
+	if err := s.client.Request("pin/rm", cid).
+		Option("recursive", false).
+		Exec(ctx, nil); err != nil && !isNotPinned(err) {
+		return err
+	}
+	// force suppresses the error if the block is already gone.
+	return s.client.Request("block/rm", cid).
+		Option("force", true).
+		Exec(ctx, nil)
 }
 
 func (s *ipfsClient) Hash(x []byte) cadata.ID {
@@ -72,3 +145,39 @@ func (s *ipfsClient) Hash(x []byte) cadata.ID {
 func (s *ipfsClient) MaxSize() int {
 	return MaxBlobSize
 }
+
+// listPins returns the IDs of all the directly pinned blocks which could have been posted to the store, in order.
+func (s *ipfsClient) listPins(ctx context.Context) ([]cadata.ID, error) {
+	var out struct {
+		Keys map[string]ipfsapi.PinInfo
+	}
+	if err := s.client.Request("pin/ls").
+		Option("type", ipfsapi.DirectPin).
+		Exec(ctx, &out); err != nil {
+		return nil, err
+	}
+	ids := make([]cadata.ID, 0, len(out.Keys))
+	for k := range out.Keys {
+		id, err := decodeCID(k)
+		if err != nil {
+			// pinned by something other than this store.
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Compare(ids[j]) < 0
+	})
+	return ids, nil
+}
+
+func convertError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		return cadata.ErrNotFound
+	}
+	return err
+}
+
+func isNotPinned(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "not pinned")
+}
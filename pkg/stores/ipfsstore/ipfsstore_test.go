@@ -0,0 +1,158 @@
+package ipfsstore
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/brendoncarroll/go-state/cadata/storetest"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestStore(t *testing.T) {
+	storetest.TestStore(t, func(t testing.TB) cadata.Store {
+		return newTestStore(t)
+	})
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	id, err := s.Post(ctx, []byte("hello"))
+	require.NoError(t, err)
+	yes, err := cadata.Exists(ctx, s, id)
+	require.NoError(t, err)
+	require.True(t, yes)
+
+	require.NoError(t, s.Delete(ctx, id))
+	yes, err = cadata.Exists(ctx, s, id)
+	require.NoError(t, err)
+	require.False(t, yes)
+	_, err = cadata.GetBytes(ctx, s, id)
+	require.ErrorIs(t, err, cadata.ErrNotFound)
+	// deleting again is not an error.
+	require.NoError(t, s.Delete(ctx, id))
+}
+
+func TestCID(t *testing.T) {
+	id := blake2b.Sum256([]byte("hello"))
+	cid := encodeCID(id)
+	require.True(t, strings.HasPrefix(cid, "bafk2bza"), cid)
+	id2, err := decodeCID(cid)
+	require.NoError(t, err)
+	require.Equal(t, cadata.ID(id), id2)
+}
+
+func newTestStore(t testing.TB) cadata.Store {
+	srv := httptest.NewServer(newFakeIPFS())
+	t.Cleanup(srv.Close)
+	return New(ipfsapi.NewShell(srv.URL))
+}
+
+// fakeIPFS implements the parts of the IPFS HTTP API used by the store.
+type fakeIPFS struct {
+	mu     sync.Mutex
+	blocks map[string][]byte
+	pins   map[string]struct{}
+}
+
+func newFakeIPFS() *fakeIPFS {
+	return &fakeIPFS{
+		blocks: map[string][]byte{},
+		pins:   map[string]struct{}{},
+	}
+}
+
+func (f *fakeIPFS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q := r.URL.Query()
+	arg := q.Get("arg")
+	switch strings.TrimPrefix(r.URL.Path, "/api/v0/") {
+	case "block/put":
+		mr, err := r.MultipartReader()
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		part, err := mr.NextPart()
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		if q.Get("mhtype") != DefaultMHType {
+			writeError(w, "unsupported mhtype")
+			return
+		}
+		key := encodeCID(blake2b.Sum256(data))
+		f.blocks[key] = data
+		writeJSON(w, map[string]any{"Key": key, "Size": len(data)})
+	case "block/get":
+		data, exists := f.blocks[arg]
+		if !exists {
+			writeError(w, "block not found")
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write(data)
+	case "block/rm":
+		if _, exists := f.blocks[arg]; !exists && q.Get("force") != "true" {
+			writeError(w, "block not found")
+			return
+		}
+		delete(f.blocks, arg)
+		writeJSON(w, map[string]any{"Hash": arg})
+	case "pin/add":
+		if _, exists := f.blocks[arg]; !exists {
+			writeError(w, "block not found")
+			return
+		}
+		f.pins[arg] = struct{}{}
+		writeJSON(w, map[string]any{"Pins": []string{arg}})
+	case "pin/rm":
+		if _, exists := f.pins[arg]; !exists {
+			writeError(w, arg+" is not pinned")
+			return
+		}
+		delete(f.pins, arg)
+		writeJSON(w, map[string]any{"Pins": []string{arg}})
+	case "pin/ls":
+		keys := map[string]any{}
+		for k := range f.pins {
+			if arg == "" || arg == k {
+				keys[k] = map[string]string{"Type": ipfsapi.DirectPin}
+			}
+		}
+		if arg != "" && len(keys) == 0 {
+			writeError(w, arg+" is not pinned")
+			return
+		}
+		writeJSON(w, map[string]any{"Keys": keys})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, x any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(x)
+}
+
+func writeError(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]any{"Message": msg, "Code": 0, "Type": "error"})
+}
@@ -0,0 +1,326 @@
+package webfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is a format which Export can write.
+type ArchiveFormat string
+
+const (
+	ArchiveTar = ArchiveFormat("tar")
+	ArchiveZip = ArchiveFormat("zip")
+)
+
+// Export writes everything at p to w as an archive in format.
+// Names in the archive are relative to p, or the name of the file if p is a file.
+// Modes, modification times and empty directories are preserved.
+// Volumes configured within p are exported as directories holding their contents, next to their configs.
+func (fs *FS) Export(ctx context.Context, p string, w io.Writer, format ArchiveFormat) error {
+	var aw archiveWriter
+	switch format {
+	case ArchiveTar:
+		aw = tarWriter{tar.NewWriter(w)}
+	case ArchiveZip:
+		aw = zipWriter{zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+	p = cleanPath(p)
+	info, err := fs.Stat(ctx, p)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = fs.exportDir(ctx, aw, p, "")
+	} else {
+		err = fs.exportEntry(ctx, aw, p, path.Base(p), info)
+	}
+	if err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// exportDir writes the contents of the directory at p, with names prefixed by name.
+func (fs *FS) exportDir(ctx context.Context, aw archiveWriter, p, name string) error {
+	f, err := fs.Open(ctx, p)
+	if err != nil {
+		return err
+	}
+	ents, err := f.ReadDir(0)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	for _, ent := range ents {
+		info, err := ent.Info()
+		if err != nil {
+			return err
+		}
+		if err := fs.exportEntry(ctx, aw, path.Join(p, ent.Name()), path.Join(name, ent.Name()), info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *FS) exportEntry(ctx context.Context, aw archiveWriter, p, name string, info iofs.FileInfo) error {
+	if info.IsDir() {
+		if err := aw.WriteDir(name, info); err != nil {
+			return err
+		}
+		return fs.exportDir(ctx, aw, p, name)
+	}
+	f, err := fs.Open(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return aw.WriteFile(name, info, f)
+}
+
+type archiveWriter interface {
+	WriteDir(name string, info iofs.FileInfo) error
+	WriteFile(name string, info iofs.FileInfo, r io.Reader) error
+	Close() error
+}
+
+type tarWriter struct {
+	w *tar.Writer
+}
+
+func (w tarWriter) WriteDir(name string, info iofs.FileInfo) error {
+	return w.writeHeader(name+"/", info)
+}
+
+func (w tarWriter) WriteFile(name string, info iofs.FileInfo, r io.Reader) error {
+	if err := w.writeHeader(name, info); err != nil {
+		return err
+	}
+	_, err := io.Copy(w.w, r)
+	return err
+}
+
+func (w tarWriter) writeHeader(name string, info iofs.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	// PAX records keep the full precision of modification times.
+	hdr.Format = tar.FormatPAX
+	return w.w.WriteHeader(hdr)
+}
+
+func (w tarWriter) Close() error {
+	return w.w.Close()
+}
+
+type zipWriter struct {
+	w *zip.Writer
+}
+
+func (w zipWriter) WriteDir(name string, info iofs.FileInfo) error {
+	_, err := w.create(name+"/", info)
+	return err
+}
+
+func (w zipWriter) WriteFile(name string, info iofs.FileInfo, r io.Reader) error {
+	fw, err := w.create(name, info)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+func (w zipWriter) create(name string, info iofs.FileInfo) (io.Writer, error) {
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = name
+	if !info.IsDir() {
+		hdr.Method = zip.Deflate
+	}
+	return w.w.CreateHeader(hdr)
+}
+
+func (w zipWriter) Close() error {
+	return w.w.Close()
+}
+
+// Import adds the contents of the archive in r, which is size bytes long, to the directory at dst.
+// The archive can be a tar file, a gzipped tar file, or a zip file, the format is detected from its contents.
+// Modes, modification times and empty directories are preserved.
+// Entries which are neither regular files nor directories are skipped.
+// Everything is committed in one transaction, so readers never see a partial import.
+// A volume config in the archive mounts the volume it describes, so the entries for the volume's contents,
+// as written by Export, are skipped instead of being hidden under the mount.
+func (fs *FS) Import(ctx context.Context, dst string, r io.ReaderAt, size int64) error {
+	dst = cleanPath(dst)
+	forEach, err := archiveIterator(r, size)
+	if err != nil {
+		return err
+	}
+	mounts := map[string]struct{}{}
+	if err := forEach(func(ent archiveEntry, r io.Reader) error {
+		name, err := cleanArchiveName(ent.Name)
+		if err != nil {
+			return err
+		}
+		if mountName := strings.TrimSuffix(name, ".webfs"); ent.Mode.IsRegular() && mountName != name && path.Base(name) != ".webfs" {
+			mounts[mountName] = struct{}{}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return fs.Txn(ctx, func(tx *Tx) error {
+		if err := tx.Mkdir(dst); err != nil {
+			return err
+		}
+		// directories are touched by adding their contents, so their times are set last.
+		dirTimes := map[string]time.Time{}
+		if err := forEach(func(ent archiveEntry, r io.Reader) error {
+			name, err := cleanArchiveName(ent.Name)
+			if err != nil {
+				return err
+			}
+			if name == "" || isMounted(mounts, name) {
+				return nil
+			}
+			p := path.Join(dst, name)
+			switch {
+			case ent.Mode.IsDir():
+				if err := tx.Mkdir(p); err != nil {
+					return err
+				}
+				dirTimes[p] = ent.ModTime
+			case ent.Mode.IsRegular():
+				if err := tx.PutFile(p, r); err != nil {
+					return err
+				}
+				if err := tx.Chtimes(p, ent.ModTime); err != nil {
+					return err
+				}
+			default:
+				return nil
+			}
+			return tx.Chmod(p, ent.Mode)
+		}); err != nil {
+			return err
+		}
+		for p, mtime := range dirTimes {
+			if err := tx.Chtimes(p, mtime); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// isMounted returns true if p is one of mounts, or is within one of them.
+func isMounted(mounts map[string]struct{}, p string) bool {
+	for ; p != ""; p = parentOf(p) {
+		if _, exists := mounts[p]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+type archiveEntry struct {
+	Name    string
+	Mode    iofs.FileMode
+	ModTime time.Time
+}
+
+// archiveIterator returns a function which calls fn with each entry in the archive in r, and a reader for its contents.
+func archiveIterator(r io.ReaderAt, size int64) (func(fn func(archiveEntry, io.Reader) error) error, error) {
+	magic := make([]byte, 4)
+	n, err := r.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	magic = magic[:n]
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return func(fn func(archiveEntry, io.Reader) error) error {
+			return forEachZipEntry(zr, fn)
+		}, nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return func(fn func(archiveEntry, io.Reader) error) error {
+			gr, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+			if err != nil {
+				return err
+			}
+			defer gr.Close()
+			return forEachTarEntry(tar.NewReader(gr), fn)
+		}, nil
+	default:
+		return func(fn func(archiveEntry, io.Reader) error) error {
+			return forEachTarEntry(tar.NewReader(io.NewSectionReader(r, 0, size)), fn)
+		}, nil
+	}
+}
+
+func forEachTarEntry(tr *tar.Reader, fn func(archiveEntry, io.Reader) error) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ent := archiveEntry{Name: hdr.Name, Mode: hdr.FileInfo().Mode(), ModTime: hdr.ModTime}
+		if err := fn(ent, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func forEachZipEntry(zr *zip.Reader, fn func(archiveEntry, io.Reader) error) error {
+	for _, zf := range zr.File {
+		ent := archiveEntry{Name: zf.Name, Mode: zf.Mode(), ModTime: zf.Modified}
+		if err := func() error {
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			return fn(ent, rc)
+		}(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanArchiveName returns the path of an archive entry relative to the import destination.
+// Names which would escape the destination are rejected.
+func cleanArchiveName(name string) (string, error) {
+	p := cleanPath(path.Clean("/" + name))
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("archive entry %q is outside of the destination", name)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,135 @@
+package webfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	iofs "io/fs"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	src := newTestWebFS(t)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, src.Txn(ctx, func(tx *Tx) error {
+		for p, data := range map[string]string{"a.txt": "a", "dir/b.txt": "b", "dir/run.sh": "#!/bin/sh"} {
+			if err := tx.PutFile(p, strings.NewReader(data)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Chmod("dir/run.sh", 0o755); err != nil {
+			return err
+		}
+		if err := tx.Mkdir("empty"); err != nil {
+			return err
+		}
+		for _, p := range []string{"a.txt", "dir/b.txt", "dir/run.sh", "dir", "empty"} {
+			if err := tx.Chtimes(p, mtime); err != nil {
+				return err
+			}
+			mtime = mtime.Add(time.Hour)
+		}
+		return nil
+	}))
+	expected := describeTree(t, src, "")
+	require.Contains(t, expected, "dir/run.sh -rwxr-xr-x 2020-01-02 05:04:05 +0000 UTC #!/bin/sh")
+	require.Contains(t, expected, "empty drwxr-xr-x 2020-01-02 07:04:05 +0000 UTC")
+
+	for _, format := range []ArchiveFormat{ArchiveTar, ArchiveZip} {
+		t.Run(string(format), func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			require.NoError(t, src.Export(ctx, "", buf, format))
+			dst := newTestWebFS(t)
+			require.NoError(t, dst.Import(ctx, "imported", bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+			require.Equal(t, expected, describeTree(t, dst, "imported"))
+		})
+	}
+
+	t.Run("tar.gz", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		gw := gzip.NewWriter(buf)
+		require.NoError(t, src.Export(ctx, "dir", gw, ArchiveTar))
+		require.NoError(t, gw.Close())
+		dst := newTestWebFS(t)
+		require.NoError(t, dst.Import(ctx, "", bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+		require.Equal(t, describeTree(t, src, "dir"), describeTree(t, dst, ""))
+	})
+}
+
+func TestExportNested(t *testing.T) {
+	ctx := context.Background()
+	src := newTestWebFS(t)
+	require.NoError(t, src.PutFile(ctx, "top.txt", strings.NewReader("top")))
+	putVolumeSpec(t, src, "vol.webfs", VolumeSpec{
+		Cell:  CellSpec{Memory: &struct{}{}},
+		Store: StoreSpec{Memory: &struct{}{}},
+		Salt:  []byte("nested"),
+	})
+	require.NoError(t, src.PutFile(ctx, "vol/inner.txt", strings.NewReader("inner")))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, src.Export(ctx, "", buf, ArchiveTar))
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, strings.TrimSuffix(hdr.Name, "/"))
+	}
+	require.Equal(t, []string{"top.txt", "vol.webfs", "vol", "vol/inner.txt"}, names)
+
+	// the config mounts a new memory volume, and the contents of the old one are not hidden under it.
+	dst := newTestWebFS(t)
+	require.NoError(t, dst.Import(ctx, "imported", bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	require.Equal(t, "top", catString(t, dst, "imported/top.txt"))
+	require.Empty(t, describeTree(t, dst, "imported/vol"))
+	_, err := dst.root.Stat(ctx, "imported/vol")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func TestImportOutside(t *testing.T) {
+	ctx := context.Background()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../x.txt", Mode: 0o644, Size: 1}))
+	_, err := tw.Write([]byte("x"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	wfs := newTestWebFS(t)
+	require.Error(t, wfs.Import(ctx, "dst", bytes.NewReader(buf.Bytes()), int64(buf.Len())))
+	_, err = wfs.Stat(ctx, "dst")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+// describeTree returns a line for everything below p, with its mode, modification time and contents.
+func describeTree(t testing.TB, wfs *FS, p string) []string {
+	var ret []string
+	require.NoError(t, wfs.Ls(context.Background(), p, func(ent iofs.DirEntry) error {
+		p2 := path.Join(p, ent.Name())
+		info, err := ent.Info()
+		require.NoError(t, err)
+		line := strings.Join([]string{ent.Name(), info.Mode().String(), info.ModTime().UTC().String()}, " ")
+		if info.IsDir() {
+			ret = append(ret, line)
+			for _, sub := range describeTree(t, wfs, p2) {
+				ret = append(ret, ent.Name()+"/"+sub)
+			}
+		} else {
+			ret = append(ret, line+" "+catString(t, wfs, p2))
+		}
+		return nil
+	}))
+	return ret
+}
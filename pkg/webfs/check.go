@@ -0,0 +1,189 @@
+package webfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"path"
+	"strings"
+
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/gotvc/got/pkg/gdat"
+	"github.com/gotvc/got/pkg/gotfs"
+	"github.com/gotvc/got/pkg/gotkv"
+	"google.golang.org/protobuf/proto"
+)
+
+// Problem is something wrong with a volume, found by Check.
+type Problem struct {
+	// Path is the file or directory which is affected.
+	Path string
+	// Blob is the blob which is missing or corrupt, if the problem is with a blob.
+	Blob cadata.ID
+	Err  error
+}
+
+func (p Problem) String() string {
+	if p.Blob != (cadata.ID{}) {
+		return fmt.Sprintf("%s: blob %v: %v", p.Path, p.Blob, p.Err)
+	}
+	return fmt.Sprintf("%s: %v", p.Path, p.Err)
+}
+
+// Check verifies everything at p, and returns any problems found.
+// Every blob referenced by the files at p must exist, and hash to its ID.
+// Volume configs at p must be valid, and the volumes they describe are also checked.
+// The returned error is only non-nil if checking could not be done at all.
+func (fs *FS) Check(ctx context.Context, p string) ([]Problem, error) {
+	p = cleanPath(p)
+	res, err := fs.resolve(ctx, fs.root, p)
+	if err != nil {
+		return nil, err
+	}
+	c := &checker{fs: fs, checked: map[cadata.ID]error{}}
+	prefix := strings.Trim(strings.TrimSuffix(p, res.Path), "/")
+	if err := c.checkVolume(ctx, res.VM, prefix, res.Path); err != nil {
+		return nil, err
+	}
+	return c.problems, nil
+}
+
+type checker struct {
+	fs       *FS
+	problems []Problem
+	// checked holds the result of checking each blob, blobs are often shared between files.
+	checked map[cadata.ID]error
+}
+
+func (c *checker) report(p string, id cadata.ID, err error) {
+	c.problems = append(c.problems, Problem{Path: p, Blob: id, Err: err})
+}
+
+// checkVolume checks everything at p in v, which is mounted at prefix.
+func (c *checker) checkVolume(ctx context.Context, v *volumeMount, prefix, p string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	root, err := readRoot(ctx, v.vol.Cell)
+	if err != nil {
+		c.report(prefix, cadata.ID{}, fmt.Errorf("reading cell: %w", err))
+		return nil
+	}
+	if root == nil {
+		return nil
+	}
+	s := verifyingStore{Store: v.vol.Store}
+	// the mount's operator caches blobs, which would then not be read from s.
+	kvop := newMetaOperator(v.spec)
+	var current string
+	var configPaths []string
+	if err := kvop.ForEach(ctx, s, *root, gotfs.SpanForPath(p), func(ent gotkv.Entry) error {
+		if _, ok := parseExtentKey(ent.Key); ok {
+			c.checkExtent(ctx, s, path.Join(prefix, current), ent.Value)
+			return nil
+		}
+		current = strings.Trim(string(ent.Key), "/")
+		var info gotfs.Info
+		if err := proto.Unmarshal(ent.Value, &info); err != nil {
+			c.report(path.Join(prefix, current), cadata.ID{}, fmt.Errorf("invalid info: %w", err))
+			return nil
+		}
+		if strings.HasSuffix(current, ".webfs") && iofs.FileMode(info.Mode).IsRegular() {
+			configPaths = append(configPaths, current)
+		}
+		return nil
+	}); err != nil {
+		// the rest of the metadata cannot be reached.
+		var be blobError
+		errors.As(err, &be)
+		c.report(path.Join(prefix, p), be.ID, fmt.Errorf("reading metadata after %q: %w", current, err))
+		return nil
+	}
+	for _, configPath := range configPaths {
+		if err := c.checkConfig(ctx, v, *root, prefix, configPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkConfig checks the volume config at p in root, and the volume it describes.
+func (c *checker) checkConfig(ctx context.Context, v *volumeMount, root gotfs.Root, prefix, p string) error {
+	fsPath := path.Join(prefix, p)
+	spec, err := loadWebFSConfig(ctx, &v.gotfs, v.vol.Store, root, p)
+	if err != nil {
+		c.report(fsPath, cadata.ID{}, err)
+		return nil
+	}
+	if spec == nil {
+		return nil
+	}
+	v2, err := c.fs.volumeForSpec(*spec)
+	if err != nil {
+		c.report(fsPath, cadata.ID{}, fmt.Errorf("invalid volume spec: %w", err))
+		return nil
+	}
+	return c.checkVolume(ctx, v2, strings.TrimSuffix(fsPath, ".webfs"), "")
+}
+
+// checkExtent checks the blob referenced by an extent of the file at p.
+func (c *checker) checkExtent(ctx context.Context, s verifyingStore, p string, data []byte) {
+	var ext gotfs.Extent
+	if err := proto.Unmarshal(data, &ext); err != nil {
+		c.report(p, cadata.ID{}, fmt.Errorf("invalid extent: %w", err))
+		return
+	}
+	ref, err := gdat.ParseRef(ext.Ref)
+	if err != nil {
+		c.report(p, cadata.ID{}, fmt.Errorf("invalid extent: %w", err))
+		return
+	}
+	err, checked := c.checked[ref.CID]
+	if !checked {
+		buf := make([]byte, s.MaxSize())
+		_, err = s.Get(ctx, ref.CID, buf)
+		c.checked[ref.CID] = err
+	}
+	if err != nil {
+		c.report(p, ref.CID, unwrapBlobError(err))
+	}
+}
+
+// verifyingStore checks that every blob read from it hashes to its ID.
+// Errors from Get are blobErrors, so the blob can be identified from errors deep in gotkv.
+type verifyingStore struct {
+	cadata.Store
+}
+
+func (s verifyingStore) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error) {
+	n, err := s.Store.Get(ctx, id, buf)
+	if err != nil {
+		return 0, blobError{ID: id, Err: err}
+	}
+	if err := cadata.Check(s.Store.Hash, id, buf[:n]); err != nil {
+		return 0, blobError{ID: id, Err: err}
+	}
+	return n, nil
+}
+
+type blobError struct {
+	ID  cadata.ID
+	Err error
+}
+
+func (e blobError) Error() string {
+	return fmt.Sprintf("blob %v: %v", e.ID, e.Err)
+}
+
+func (e blobError) Unwrap() error {
+	return e.Err
+}
+
+func unwrapBlobError(err error) error {
+	var be blobError
+	if errors.As(err, &be) {
+		return be.Err
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package webfs
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/gotvc/got/pkg/gdat"
+	"github.com/gotvc/got/pkg/gotfs"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader(strings.Repeat("a", 1000))))
+	require.NoError(t, wfs.PutFile(ctx, "dir/b.txt", strings.NewReader("b")))
+	putVolumeSpec(t, wfs, "vol.webfs", newTestVolumeSpec(t))
+	require.NoError(t, wfs.PutFile(ctx, "vol/c.txt", strings.NewReader("c")))
+
+	problems, err := wfs.Check(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, problems)
+
+	// a blob which is missing.
+	id := dataBlobs(t, wfs.root, "a.txt")[0]
+	data, err := cadata.GetBytes(ctx, wfs.root.vol.Store, id)
+	require.NoError(t, err)
+	require.NoError(t, wfs.root.vol.Store.Delete(ctx, id))
+	problems, err = wfs.Check(ctx, "")
+	require.NoError(t, err)
+	require.Contains(t, problems, Problem{Path: "a.txt", Blob: id, Err: cadata.ErrNotFound})
+	problems, err = wfs.Check(ctx, "dir")
+	require.NoError(t, err)
+	require.Empty(t, problems)
+
+	// a blob which does not match its ID.
+	wfs.root.vol.Store = corruptStore{Store: wfs.root.vol.Store, bad: id}
+	_, err = wfs.root.vol.Store.Post(ctx, data)
+	require.NoError(t, err)
+	problems, err = wfs.Check(ctx, "a.txt")
+	require.NoError(t, err)
+	require.Equal(t, []Problem{{Path: "a.txt", Blob: id, Err: cadata.ErrBadData}}, problems)
+}
+
+func TestCheckMetadata(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader("a")))
+	// reading the files leaves the metadata in the mount's caches.
+	require.NoError(t, wfs.Cat(ctx, "a.txt", io.Discard))
+	problems, err := wfs.Check(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, problems)
+
+	root, err := readRoot(ctx, wfs.root.vol.Cell)
+	require.NoError(t, err)
+	id := root.Ref.CID
+	store := wfs.root.vol.Store
+	wfs.root.vol.Store = corruptStore{Store: store, bad: id}
+	problems, err = wfs.Check(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	require.Equal(t, id, problems[0].Blob)
+	require.ErrorIs(t, problems[0].Err, cadata.ErrBadData)
+
+	wfs.root.vol.Store = store
+	require.NoError(t, store.Delete(ctx, id))
+	problems, err = wfs.Check(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	require.Equal(t, id, problems[0].Blob)
+	require.ErrorIs(t, problems[0].Err, cadata.ErrNotFound)
+}
+
+func TestCheckConfig(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	require.NoError(t, wfs.PutFile(ctx, "bad.webfs", strings.NewReader("{")))
+	putVolumeSpec(t, wfs, "empty.webfs", VolumeSpec{})
+	problems, err := wfs.Check(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, problems, 2)
+	require.Equal(t, "bad.webfs", problems[0].Path)
+	require.Equal(t, "empty.webfs", problems[1].Path)
+}
+
+// dataBlobs returns the IDs of the blobs holding the data for the file at p.
+func dataBlobs(t testing.TB, v *volumeMount, p string) (ret []cadata.ID) {
+	ctx := context.Background()
+	root, err := readRoot(ctx, v.vol.Cell)
+	require.NoError(t, err)
+	ents, err := v.listExtents(ctx, *root, p)
+	require.NoError(t, err)
+	// the first entry is the file's info.
+	for _, ent := range ents[1:] {
+		var ext gotfs.Extent
+		require.NoError(t, proto.Unmarshal(ent.Value, &ext))
+		ref, err := gdat.ParseRef(ext.Ref)
+		require.NoError(t, err)
+		ret = append(ret, ref.CID)
+	}
+	return ret
+}
+
+// corruptStore returns data which does not match the ID for the blob bad.
+type corruptStore struct {
+	cadata.Store
+	bad cadata.ID
+}
+
+func (s corruptStore) Get(ctx context.Context, id cadata.ID, buf []byte) (int, error) {
+	n, err := s.Store.Get(ctx, id, buf)
+	if err == nil && id == s.bad && n > 0 {
+		buf[0] ^= 0xff
+	}
+	return n, err
+}
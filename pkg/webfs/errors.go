@@ -1,9 +1,12 @@
 package webfs
 
 import (
+	"errors"
 	"fmt"
 )
 
+var errStopIter = errors.New("stop iteration")
+
 // ErrBadConfig is returned when WebFS encounters an invalid config which it cannot mount.
 type ErrBadConfig struct {
 	Path  string
@@ -18,3 +21,27 @@ func (e ErrBadConfig) Cause() error {
 func (e ErrBadConfig) Error() string {
 	return fmt.Sprintf("bad webfs config at path %q. data=%q error=%v", e.Path, e.Data, e.Inner)
 }
+
+// ErrReadOnly is returned when attempting to modify a volume which cannot be written to,
+// such as one with a literal cell.
+type ErrReadOnly struct {
+	// Path is the path within the volume
+	Path string
+}
+
+func (e ErrReadOnly) Error() string {
+	return fmt.Sprintf("cannot modify %q: volume is read-only", e.Path)
+}
+
+// ErrNoHistory is returned when asking for previous versions of a volume which does not keep history.
+var ErrNoHistory = errors.New("webfs: volume does not keep history")
+
+// ErrConflict is returned when another writer changed the same path concurrently, and the
+// conflict policy is FailOnConflict.
+type ErrConflict struct {
+	Path string
+}
+
+func (e ErrConflict) Error() string {
+	return fmt.Sprintf("conflicting concurrent changes to %q", e.Path)
+}
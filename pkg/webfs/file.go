@@ -2,33 +2,57 @@ package webfs
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	iofs "io/fs"
+	"os"
 	"time"
 
 	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/gotvc/got/pkg/gotfs"
+	"golang.org/x/crypto/blake2b"
 )
 
 var (
 	_ io.Reader   = &File{}
 	_ io.ReaderAt = &File{}
+	_ io.Writer   = &File{}
+	_ io.WriterAt = &File{}
+	_ io.Seeker   = &File{}
 )
 
+// File is an open file or directory in a WebFS volume.
+// Reads are served from a snapshot of the volume taken when the File was opened, see Refresh.
+// Methods which do not take a context use the one passed to Open, so cancelling it aborts them.
 type File struct {
 	vol  *volumeMount
 	path string
+	flag int
+	// root is the snapshot of the volume the File reads from, it is nil if the volume is empty.
+	root *gotfs.Root
 
+	// ctx is the context the File was opened with, it is used by methods which do not take a context.
 	ctx    context.Context
 	offset int64
+	// dirLast is the name of the last directory entry read from the volume, the next call to ReadDir continues after it.
+	dirLast string
+	// dirPending holds entries which were read, but did not fit in the last call to ReadDir.
+	dirPending []iofs.DirEntry
+	// fs is set if the File was opened through an FS, so that the volumes configured in a directory can be listed.
+	fs *FS
+	// w is nil unless the file was opened for writing.
+	w *fileWriter
 }
 
-func newFile(vol *volumeMount, path string) *File {
+func newFile(ctx context.Context, vol *volumeMount, root *gotfs.Root, path string) *File {
 	return &File{
 		vol:  vol,
 		path: path,
-		ctx:  context.Background(),
+		flag: os.O_RDONLY,
+		root: root,
+		ctx:  ctx,
 	}
 }
 
@@ -42,34 +66,291 @@ func (f *File) Read(p []byte) (int, error) {
 }
 
 func (f *File) ReadAt(buf []byte, offset int64) (int, error) {
-	root, err := readRoot(f.ctx, f.vol.vol.Cell)
-	if err != nil {
+	return f.ReadAtContext(f.ctx, buf, offset)
+}
+
+// ReadAtContext is like ReadAt, but it can be cancelled with ctx instead of the context the File was opened with.
+func (f *File) ReadAtContext(ctx context.Context, buf []byte, offset int64) (int, error) {
+	if err := ctx.Err(); err != nil {
 		return 0, err
 	}
-	if root == nil {
-		return 0, iofs.ErrNotExist
+	if f.flag&os.O_WRONLY != 0 {
+		return 0, f.pathError("read", iofs.ErrPermission)
 	}
 	if offset < 0 {
 		return 0, fmt.Errorf("invalid offset %d", offset)
 	}
+	if f.root == nil {
+		return 0, iofs.ErrNotExist
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	if f.w != nil {
+		return f.w.readAt(ctx, f.vol, *f.root, f.path, buf, offset)
+	}
+	// io.ReaderAt requires that buf is filled unless there is an error, so keep reading until it is full or the file ends.
 	s := f.vol.vol.Store
-	return f.vol.gotfs.ReadFileAt(f.ctx, s, s, *root, f.path, uint64(offset), buf)
+	var n int
+	for n < len(buf) {
+		n2, err := f.vol.gotfs.ReadFileAt(ctx, s, s, *f.root, f.path, uint64(offset)+uint64(n), buf[n:])
+		n += n2
+		if err != nil {
+			return n, err
+		}
+		if n2 == 0 {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// Write writes data at the current offset, or at the end of the file if it was opened with O_APPEND.
+func (f *File) Write(data []byte) (int, error) {
+	if f.w != nil && f.flag&os.O_APPEND != 0 {
+		f.offset = f.w.size
+	}
+	n, err := f.writeAt(data, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// WriteAt writes data at offset.
+// The changes are not visible outside of this File until Sync or Close is called.
+func (f *File) WriteAt(data []byte, offset int64) (int, error) {
+	if f.flag&os.O_APPEND != 0 {
+		return 0, errors.New("webfs: WriteAt not allowed on file opened with O_APPEND")
+	}
+	return f.writeAt(data, offset)
+}
+
+func (f *File) writeAt(data []byte, offset int64) (int, error) {
+	if f.w == nil {
+		return 0, f.pathError("write", iofs.ErrPermission)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("invalid offset %d", offset)
+	}
+	f.w.writeAt(data, offset)
+	return len(data), nil
+}
+
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = f.offset + offset
+	case io.SeekEnd:
+		finfo, err := f.Stat()
+		if err != nil {
+			return f.offset, err
+		}
+		next = finfo.Size() + offset
+	default:
+		return f.offset, fmt.Errorf("invalid value for whence %d", whence)
+	}
+	if next < 0 {
+		return f.offset, fmt.Errorf("seeked to negative offset: %d", next)
+	}
+	f.offset = next
+	return f.offset, nil
+}
+
+// Truncate changes the size of the file.
+// If the file grows, the new space is filled with zeros.
+func (f *File) Truncate(size int64) error {
+	if f.w == nil {
+		return f.pathError("truncate", iofs.ErrPermission)
+	}
+	if size < 0 {
+		return fmt.Errorf("invalid size %d", size)
+	}
+	f.w.truncate(size)
+	return nil
 }
 
 func (f *File) Stat() (iofs.FileInfo, error) {
-	return f.vol.Stat(f.ctx, f.path)
+	if f.root == nil {
+		// only the root of an empty volume can be opened
+		return &fileInfo{name: ".", mode: iofs.ModeDir | 0o755}, nil
+	}
+	finfo, err := f.vol.stat(f.ctx, *f.root, f.path)
+	if err != nil {
+		return nil, err
+	}
+	if f.w != nil {
+		finfo.size = f.w.size
+	}
+	return finfo, nil
+}
+
+// ContentID returns an identifier for the contents of the file, which is derived from the hashes of the blobs holding them.
+// Files with different contents never have the same ID. The same contents usually have the same ID,
+// but not always, since they can be stored in different blobs.
+// It is much cheaper than hashing the contents, because only the file's metadata is read.
+func (f *File) ContentID() ([]byte, error) {
+	if f.w != nil && f.w.dirty {
+		return nil, errors.New("webfs: file has uncommitted changes")
+	}
+	if f.root == nil {
+		return nil, f.pathError("contentid", iofs.ErrNotExist)
+	}
+	ents, err := f.vol.listExtents(f.ctx, *f.root, f.path)
+	if err != nil {
+		return nil, err
+	}
+	h, _ := blake2b.New256(nil)
+	for _, ent := range ents {
+		end, ok := parseExtentKey(ent.Key)
+		if !ok {
+			continue
+		}
+		// the rest of the key is the path of the file, which does not affect the content.
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], end)
+		h.Write(buf[:])
+		writeLenPrefixed(h, ent.Value)
+	}
+	return h.Sum(nil), nil
+}
+
+func writeLenPrefixed(w io.Writer, data []byte) {
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(len(data)))
+	w.Write(l[:])
+	w.Write(data)
+}
+
+// Refresh updates the snapshot that the File reads from to the latest version of the volume.
+// Files opened for writing cannot be refreshed while they have uncommitted changes.
+func (f *File) Refresh() error {
+	if f.w != nil && f.w.dirty {
+		return errors.New("webfs: cannot refresh file with uncommitted changes")
+	}
+	root, err := readRoot(f.ctx, f.vol.vol.Cell)
+	if err != nil {
+		return err
+	}
+	if f.w != nil {
+		if root == nil {
+			return iofs.ErrNotExist
+		}
+		s := f.vol.vol.Store
+		if _, err := f.vol.gotfs.GetFileInfo(f.ctx, s, *root, f.path); err != nil {
+			return convertError(err)
+		}
+		size, err := f.vol.gotfs.SizeOfFile(f.ctx, s, *root, f.path)
+		if err != nil {
+			return err
+		}
+		f.w = newFileWriter(f.w.mode, int64(size))
+	}
+	f.root = root
+	return nil
 }
 
+// Sync commits any changes made through the File to the volume.
+// Only the parts of the file which have changed are written to the store.
 func (f *File) Sync() error {
+	if f.w == nil || !f.w.dirty {
+		return nil
+	}
+	ctx := f.ctx
+	v := f.vol
+	fileRoot, err := f.w.build(ctx, v, *f.root, f.path)
+	if err != nil {
+		return err
+	}
+	ms := v.vol.Store
+	var next *gotfs.Root
+	if err := v.modifyRoot(ctx, f.path, func(root *gotfs.Root) (*gotfs.Root, error) {
+		var err error
+		if root == nil {
+			if root, err = v.gotfs.NewEmpty(ctx, ms); err != nil {
+				return nil, err
+			}
+		}
+		now := time.Now()
+		if root, err = v.place(ctx, *root, f.path, *fileRoot, now); err != nil {
+			return nil, err
+		}
+		if next, err = v.setModTime(ctx, *root, f.path, now); err != nil {
+			return nil, err
+		}
+		return next, nil
+	}); err != nil {
+		return err
+	}
+	f.w.committed()
+	f.root = next
 	return nil
 }
 
-func (f *File) ReadDir(n int) (ret []iofs.DirEntry, _ error) {
-	return f.vol.readDir(f.ctx, f.path, n)
+// ReadDir reads the entries of the directory, continuing from where the previous call left off.
+// It follows the semantics of fs.ReadDirFile.
+// If the File was opened through an FS, volumes configured in the directory are listed as directories next to their configs.
+func (f *File) ReadDir(n int) ([]iofs.DirEntry, error) {
+	return f.ReadDirContext(f.ctx, n)
+}
+
+// ReadDirContext is like ReadDir, but it can be cancelled with ctx instead of the context the File was opened with.
+func (f *File) ReadDirContext(ctx context.Context, n int) ([]iofs.DirEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if f.dirLast == "" && f.root != nil {
+		finfo, err := f.vol.stat(ctx, *f.root, f.path)
+		if err != nil {
+			return nil, err
+		}
+		if !finfo.IsDir() {
+			return nil, f.pathError("readdir", errors.New("not a directory"))
+		}
+	}
+	ret := f.dirPending
+	f.dirPending = nil
+	for n <= 0 || len(ret) < n {
+		limit := 0
+		if n > 0 {
+			limit = n - len(ret)
+		}
+		ents, err := f.vol.readDir(ctx, f.root, f.path, f.dirLast, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(ents) == 0 {
+			break
+		}
+		f.dirLast = ents[len(ents)-1].Name()
+		if f.fs != nil && f.root != nil {
+			if ents, err = f.fs.listMounts(ctx, f.vol, *f.root, f.path, ents); err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, ents...)
+		if n <= 0 {
+			break
+		}
+	}
+	if n > 0 && len(ret) > n {
+		f.dirPending = ret[n:]
+		ret = ret[:n]
+	}
+	if n > 0 && len(ret) == 0 {
+		return nil, io.EOF
+	}
+	return ret, nil
 }
 
+// Close commits any changes made through the File.
 func (f *File) Close() error {
-	return nil
+	return f.Sync()
+}
+
+func (f *File) pathError(op string, err error) error {
+	return &iofs.PathError{Op: op, Path: f.path, Err: err}
 }
 
 type fileInfo struct {
@@ -109,6 +390,8 @@ type dirEntry struct {
 	name    string
 	mode    iofs.FileMode
 	getInfo func() (*fileInfo, error)
+	// mount is set for the entries of volumes listed next to their configs.
+	mount bool
 }
 
 func (de *dirEntry) Name() string {
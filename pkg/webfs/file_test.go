@@ -0,0 +1,185 @@
+package webfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	mrand "math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/brendoncarroll/go-state/cells"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileWrite(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	f, err := wfs.OpenFile(ctx, "a/test", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("hello world"))
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("WORLD"), 6)
+	require.NoError(t, err)
+	// changes are visible through the handle before they are committed.
+	require.Equal(t, "hello WORLD", readAll(t, f))
+	require.Equal(t, "", catString(t, wfs, "a/test"))
+	require.NoError(t, f.Close())
+	require.Equal(t, "hello WORLD", catString(t, wfs, "a/test"))
+
+	_, err = wfs.OpenFile(ctx, "a/test", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	require.ErrorIs(t, err, os.ErrExist)
+
+	f, err = wfs.OpenFile(ctx, "a/test", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("!!!"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "hello WORLD!!!", catString(t, wfs, "a/test"))
+
+	f, err = wfs.OpenFile(ctx, "a/test", os.O_RDWR, 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(5))
+	_, err = f.WriteAt([]byte("?"), 7)
+	require.NoError(t, err)
+	require.NoError(t, f.Sync())
+	require.Equal(t, "hello\x00\x00?", catString(t, wfs, "a/test"))
+	require.NoError(t, f.Close())
+
+	f, err = wfs.OpenFile(ctx, "a/test", os.O_WRONLY|os.O_TRUNC, 0)
+	require.NoError(t, err)
+	require.Equal(t, "", catString(t, wfs, "a/test"))
+	_, err = f.Read(make([]byte, 10))
+	require.ErrorIs(t, err, os.ErrPermission)
+	require.NoError(t, f.Close())
+
+	_, err = wfs.OpenFile(ctx, "b/test", os.O_RDWR, 0)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileWriteReuse(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	data := make([]byte, 8*MaxBlobSize)
+	mrand.New(mrand.NewSource(0)).Read(data)
+	require.NoError(t, wfs.PutFile(ctx, "test", bytes.NewReader(data)))
+	store := wfs.root.vol.Store.(*cadata.MemStore)
+	before := store.Len()
+
+	f, err := wfs.OpenFile(ctx, "test", os.O_RDWR, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("small change"), int64(len(data)/2))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	copy(data[len(data)/2:], "small change")
+
+	require.Less(t, store.Len()-before, 8)
+	require.Equal(t, string(data), catString(t, wfs, "test"))
+}
+
+func TestFileSnapshot(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	require.NoError(t, wfs.PutFile(ctx, "test", strings.NewReader("version 1")))
+	cell := &countingCell{Cell: wfs.root.vol.Cell}
+	wfs.root.vol.Cell = cell
+
+	f, err := wfs.Open(ctx, "test")
+	require.NoError(t, err)
+	require.NoError(t, wfs.PutFile(ctx, "test", strings.NewReader("version 2")))
+	reads := cell.reads
+	require.Equal(t, "version 1", readAll(t, f))
+	buf := make([]byte, 1)
+	for i := 0; i < 5; i++ {
+		_, err := f.ReadAt(buf, int64(i))
+		require.NoError(t, err)
+	}
+	require.Equal(t, reads, cell.reads)
+
+	require.NoError(t, f.Refresh())
+	require.Equal(t, "version 2", readAll(t, f))
+	require.NoError(t, f.Close())
+
+	_, err = wfs.Open(ctx, "nothing")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileContext(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	require.NoError(t, wfs.PutFile(ctx, "a/test", strings.NewReader("hello")))
+
+	ctx2, cf := context.WithCancel(ctx)
+	f, err := wfs.Open(ctx2, "a/test")
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = f.ReadAt(buf, 0)
+	require.NoError(t, err)
+	cf()
+	_, err = f.ReadAt(buf, 0)
+	require.ErrorIs(t, err, context.Canceled)
+	// the context-aware variants do not depend on the context passed to Open.
+	n, err := f.ReadAtContext(ctx, buf, 0)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf[:n]))
+
+	d, err := wfs.Open(ctx2, "a")
+	require.NoError(t, err)
+	_, err = d.ReadDir(0)
+	require.ErrorIs(t, err, context.Canceled)
+	ents, err := d.ReadDirContext(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, ents, 1)
+}
+
+func TestFileReadDirPagination(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	for _, name := range []string{"a", "b", "c", "b2/x", "b2/y/z"} {
+		require.NoError(t, wfs.PutFile(ctx, "dir/"+name, strings.NewReader(name)))
+	}
+	f, err := wfs.Open(ctx, "dir")
+	require.NoError(t, err)
+	var names []string
+	for {
+		ents, err := f.ReadDir(2)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(ents), 2)
+		for _, ent := range ents {
+			names = append(names, ent.Name())
+		}
+	}
+	require.Equal(t, []string{"a", "b", "b2", "c"}, names)
+	ents, err := f.ReadDir(-1)
+	require.NoError(t, err)
+	require.Len(t, ents, 0)
+}
+
+type countingCell struct {
+	cells.Cell
+	reads int
+}
+
+func (c *countingCell) Read(ctx context.Context, buf []byte) (int, error) {
+	c.reads++
+	return c.Cell.Read(ctx, buf)
+}
+
+func readAll(t testing.TB, f *File) string {
+	_, err := f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func catString(t testing.TB, wfs *FS, p string) string {
+	buf := &bytes.Buffer{}
+	require.NoError(t, wfs.Cat(context.Background(), p, buf))
+	return buf.String()
+}
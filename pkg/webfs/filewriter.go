@@ -0,0 +1,242 @@
+package webfs
+
+import (
+	"context"
+	"io"
+	iofs "io/fs"
+
+	"github.com/gotvc/got/pkg/gotfs"
+)
+
+// fileWriter holds the changes made through a File which have not yet been committed to the volume.
+// The changes are made on top of the snapshot of the volume held by the File.
+type fileWriter struct {
+	mode iofs.FileMode
+	size int64
+	// baseSize is the length of the prefix of the file in base which is still visible.
+	// It shrinks if the file is truncated.
+	baseSize int64
+	spans    []dirtySpan
+	dirty    bool
+}
+
+// dirtySpan is a region of the file which has been written to.
+type dirtySpan struct {
+	offset int64
+	data   []byte
+}
+
+func (s dirtySpan) end() int64 {
+	return s.offset + int64(len(s.data))
+}
+
+func newFileWriter(mode iofs.FileMode, size int64) *fileWriter {
+	return &fileWriter{
+		mode:     mode,
+		size:     size,
+		baseSize: size,
+	}
+}
+
+// writeAt records data as written at offset.
+func (w *fileWriter) writeAt(data []byte, offset int64) {
+	if len(data) == 0 {
+		return
+	}
+	end := offset + int64(len(data))
+	// find the spans which overlap or are adjacent to the write.
+	first, last := len(w.spans), 0
+	for i, s := range w.spans {
+		if s.offset <= end && s.end() >= offset {
+			if i < first {
+				first = i
+			}
+			last = i + 1
+		}
+	}
+	switch {
+	case first >= last:
+		// no overlap, insert a new span.
+		i := 0
+		for i < len(w.spans) && w.spans[i].offset < offset {
+			i++
+		}
+		w.spans = append(w.spans, dirtySpan{})
+		copy(w.spans[i+1:], w.spans[i:])
+		w.spans[i] = dirtySpan{offset: offset, data: append([]byte{}, data...)}
+	case last-first == 1 && w.spans[first].offset <= offset:
+		// the common case for sequential writes, extend a single span.
+		s := &w.spans[first]
+		rel := offset - s.offset
+		if need := rel + int64(len(data)); need > int64(len(s.data)) {
+			s.data = append(s.data, make([]byte, need-int64(len(s.data)))...)
+		}
+		copy(s.data[rel:], data)
+	default:
+		begin := offset
+		if w.spans[first].offset < begin {
+			begin = w.spans[first].offset
+		}
+		if e := w.spans[last-1].end(); e > end {
+			end = e
+		}
+		merged := make([]byte, end-begin)
+		for _, s := range w.spans[first:last] {
+			copy(merged[s.offset-begin:], s.data)
+		}
+		copy(merged[offset-begin:], data)
+		w.spans[first] = dirtySpan{offset: begin, data: merged}
+		w.spans = append(w.spans[:first+1], w.spans[last:]...)
+	}
+	if end > w.size {
+		w.size = end
+	}
+	w.dirty = true
+}
+
+// truncate changes the size of the file to size.
+func (w *fileWriter) truncate(size int64) {
+	var spans []dirtySpan
+	for _, s := range w.spans {
+		if s.offset >= size {
+			continue
+		}
+		if s.end() > size {
+			s.data = s.data[:size-s.offset]
+		}
+		spans = append(spans, s)
+	}
+	w.spans = spans
+	if size < w.baseSize {
+		w.baseSize = size
+	}
+	w.size = size
+	w.dirty = true
+}
+
+// isClean returns true if no part of [begin, end) has been changed since base.
+func (w *fileWriter) isClean(begin, end int64) bool {
+	if end > w.baseSize {
+		return false
+	}
+	for _, s := range w.spans {
+		if s.offset < end && s.end() > begin {
+			return false
+		}
+	}
+	return true
+}
+
+// readAt reads from the file as it would be if the changes were committed.
+func (w *fileWriter) readAt(ctx context.Context, vm *volumeMount, base gotfs.Root, p string, buf []byte, offset int64) (int, error) {
+	if offset >= w.size {
+		return 0, io.EOF
+	}
+	want := len(buf)
+	n := want
+	if rem := w.size - offset; int64(n) > rem {
+		n = int(rem)
+	}
+	buf = buf[:n]
+	var fromBase int
+	if offset < w.baseSize {
+		fromBase = n
+		if rem := w.baseSize - offset; int64(fromBase) > rem {
+			fromBase = int(rem)
+		}
+		s := vm.vol.Store
+		for read := 0; read < fromBase; {
+			n2, err := vm.gotfs.ReadFileAt(ctx, s, s, base, p, uint64(offset)+uint64(read), buf[read:fromBase])
+			if err != nil {
+				return 0, err
+			}
+			read += n2
+		}
+	}
+	for i := fromBase; i < n; i++ {
+		buf[i] = 0
+	}
+	for _, s := range w.spans {
+		if s.offset < offset+int64(n) && s.end() > offset {
+			if s.offset >= offset {
+				copy(buf[s.offset-offset:], s.data)
+			} else {
+				copy(buf, s.data[offset-s.offset:])
+			}
+		}
+	}
+	if n < want {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// build creates a root containing only the file at the root.
+// Extents from base which have not been changed are reused instead of being written again.
+func (w *fileWriter) build(ctx context.Context, vm *volumeMount, base gotfs.Root, p string) (*gotfs.Root, error) {
+	ms, ds := vm.vol.Store, vm.vol.Store
+	b := vm.gotfs.NewBuilder(ctx, ms, ds)
+	if err := b.BeginFile("", w.mode); err != nil {
+		return nil, err
+	}
+	var offset int64
+	copyRange := func(end int64) error {
+		buf := make([]byte, MaxBlobSize)
+		for offset < end {
+			n := len(buf)
+			if rem := end - offset; int64(n) > rem {
+				n = int(rem)
+			}
+			n, err := w.readAt(ctx, vm, base, p, buf[:n], offset)
+			if err != nil {
+				return err
+			}
+			if _, err := b.Write(buf[:n]); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		return nil
+	}
+	var batch []*gotfs.Extent
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := b.WriteExtents(ctx, batch)
+		batch = batch[:0]
+		return err
+	}
+	if w.baseSize > 0 {
+		if err := vm.forEachExtent(ctx, ms, base, p, func(begin, end int64, ext *gotfs.Extent) error {
+			if end > w.baseSize {
+				return errStopIter
+			}
+			if w.isClean(begin, end) && begin == offset {
+				batch = append(batch, ext)
+				offset = end
+				return nil
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			return copyRange(end)
+		}); err != nil && err != errStopIter {
+			return nil, err
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if err := copyRange(w.size); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// committed resets the writer after the changes have been written to the volume.
+func (w *fileWriter) committed() {
+	w.baseSize = w.size
+	w.spans = nil
+	w.dirty = false
+}
@@ -0,0 +1,232 @@
+package webfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/brendoncarroll/go-state"
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/gotvc/got/pkg/gotfs"
+	"github.com/gotvc/got/pkg/gotvc"
+	"github.com/gotvc/got/pkg/stores"
+
+	"github.com/brendoncarroll/webfs/pkg/cells/gotcells"
+)
+
+// GCResult describes the blobs found by a garbage collection.
+type GCResult struct {
+	// Reachable is the number of blobs in the store which are still in use.
+	Reachable int
+	// Unreachable is the number of blobs which were deleted, or would be deleted by a dry run.
+	Unreachable int
+	// UnreachableBytes is the total size of the unreachable blobs.
+	// It is only counted by a dry run, since every blob has to be read to find its size.
+	UnreachableBytes int64
+}
+
+// GCOption configures a garbage collection.
+type GCOption func(c *gcConfig)
+
+type gcConfig struct {
+	dryRun bool
+}
+
+// GCDryRun finds the unreachable blobs, without deleting them.
+func GCDryRun() GCOption {
+	return func(c *gcConfig) {
+		c.dryRun = true
+	}
+}
+
+// GC deletes the blobs in the root volume's store which are not reachable from the root volume.
+//
+// Blobs are reachable from the current root of a volume, and from every version in its history.
+// Nested volumes which use the same store are followed, as are nested volumes in other stores,
+// in case they contain volumes which use the root volume's store.
+//
+// Snapshots are only reachable while their specs are stored in a .webfs file within the root volume,
+// so the blobs of a snapshot which was printed but never stored can be deleted.
+//
+// GC must not run at the same time as anything else writing to the store,
+// since blobs which have been written but not yet committed to a cell are unreachable.
+func (fs *FS) GC(ctx context.Context, opts ...GCOption) (*GCResult, error) {
+	var config gcConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	target := fs.root
+	gc := &collector{
+		fs:      fs,
+		target:  target,
+		set:     stores.MemSet{},
+		visited: map[[32]byte]struct{}{},
+	}
+	if err := gc.walkVolume(ctx, target); err != nil {
+		return nil, err
+	}
+
+	store := target.vol.Store
+	res := &GCResult{}
+	var unreachable []cadata.ID
+	if err := cadata.ForEach(ctx, store, state.TotalSpan[cadata.ID](), func(id cadata.ID) error {
+		if _, exists := gc.set[id]; exists {
+			res.Reachable++
+		} else {
+			unreachable = append(unreachable, id)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	res.Unreachable = len(unreachable)
+	if config.dryRun {
+		buf := make([]byte, store.MaxSize())
+		for _, id := range unreachable {
+			n, err := store.Get(ctx, id, buf)
+			if err != nil && !cadata.IsNotFound(err) {
+				return nil, err
+			}
+			res.UnreachableBytes += int64(n)
+		}
+		return res, nil
+	}
+	for _, id := range unreachable {
+		if err := store.Delete(ctx, id); err != nil && !cadata.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// collector finds the blobs in target's store which are reachable from target.
+type collector struct {
+	fs     *FS
+	target *volumeMount
+	// set holds the reachable blobs, it may include blobs which are not in the store.
+	set stores.MemSet
+	// visited holds the fingerprints of the volumes which have been walked.
+	visited map[[32]byte]struct{}
+}
+
+// walkVolume marks everything reachable from v, including its history and nested volumes.
+func (gc *collector) walkVolume(ctx context.Context, v *volumeMount) error {
+	if _, exists := gc.visited[v.fingerprint]; exists {
+		return nil
+	}
+	gc.visited[v.fingerprint] = struct{}{}
+	root, err := readRoot(ctx, v.vol.Cell)
+	if err != nil {
+		return err
+	}
+	if root != nil {
+		if err := gc.walkRoot(ctx, v, *root); err != nil {
+			return err
+		}
+	}
+	if !keepsHistory(v.spec) {
+		return nil
+	}
+	bc, ok := v.vol.Cell.(*gotcells.BranchCell)
+	if !ok {
+		// deleting anything could destroy history that we are unable to see.
+		return fmt.Errorf("cannot collect garbage: the history of volume %x is not accessible", v.fingerprint[:8])
+	}
+	head, err := bc.Head(ctx)
+	if err != nil || head == nil {
+		return err
+	}
+	vcShared := gc.sharesStore(v, vcStoreSpec(v.spec))
+	return gotvc.ForEach(ctx, bc.VCStore(), head.Parents, func(ref gotvc.Ref, snap gotvc.Snapshot) error {
+		if vcShared {
+			if err := gc.set.Add(ctx, ref.CID); err != nil {
+				return err
+			}
+		}
+		if root := snapshotRoot(snap); root != nil {
+			return gc.walkRoot(ctx, v, *root)
+		}
+		return nil
+	})
+}
+
+// walkRoot marks the blobs reachable from root, which is a root of v, and walks any volumes configured in it.
+func (gc *collector) walkRoot(ctx context.Context, v *volumeMount, root gotfs.Root) error {
+	ms := v.vol.Store
+	if gc.sharesStore(v, v.spec.Store) {
+		if err := gotfs.Populate(ctx, ms, root, gc.set, gc.set); err != nil {
+			return err
+		}
+	}
+	var configPaths []string
+	if err := v.gotfs.ForEachFile(ctx, ms, root, "", func(p string, _ *gotfs.Info) error {
+		if strings.HasSuffix(p, ".webfs") {
+			configPaths = append(configPaths, p)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, p := range configPaths {
+		spec, err := loadWebFSConfig(ctx, &v.gotfs, ms, root, p)
+		if err != nil {
+			return err
+		}
+		if spec == nil {
+			continue
+		}
+		v2, err := gc.fs.volumeForSpec(*spec)
+		if err != nil {
+			return fmt.Errorf("cannot collect garbage: volume configured at %q: %w", p, err)
+		}
+		if err := gc.walkVolume(ctx, v2); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sharesStore returns true if spec, used by v, describes the store being collected.
+func (gc *collector) sharesStore(v *volumeMount, spec StoreSpec) bool {
+	a, _ := json.Marshal(spec)
+	b, _ := json.Marshal(gc.target.spec.Store)
+	if string(a) != string(b) {
+		return false
+	}
+	// memory stores are never shared between volumes.
+	return v == gc.target || spec.Memory == nil
+}
+
+// volumeForSpec returns the mounted volume for spec if there is one, otherwise a new mount which is not cached.
+func (fs *FS) volumeForSpec(spec VolumeSpec) (*volumeMount, error) {
+	if vm := fs.mounts.get(spec.Fingerprint()); vm != nil {
+		return vm, nil
+	}
+	return fs.newVolumeMount(spec)
+}
+
+// keepsHistory returns true if the volume described by spec keeps previous versions.
+func keepsHistory(spec VolumeSpec) bool {
+	return spec.History || findGotBranch(spec.Cell) != nil
+}
+
+// vcStoreSpec returns the spec for the store holding the snapshots of a volume which keeps history.
+func vcStoreSpec(spec VolumeSpec) StoreSpec {
+	if gb := findGotBranch(spec.Cell); gb != nil && !gb.VCStore.isZero() {
+		return gb.VCStore
+	}
+	return spec.Store
+}
+
+// findGotBranch returns the got_branch cell within spec, or nil if there is not one.
+func findGotBranch(spec CellSpec) *GotBranchCellSpec {
+	switch {
+	case spec.GotBranch != nil:
+		return spec.GotBranch
+	case spec.AEAD != nil:
+		return findGotBranch(spec.AEAD.Inner)
+	default:
+		return nil
+	}
+}
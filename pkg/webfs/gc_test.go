@@ -0,0 +1,75 @@
+package webfs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/brendoncarroll/go-state"
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGC(t *testing.T) {
+	ctx := context.Background()
+	spec := newTestVolumeSpec(t)
+	wfs, err := New(spec, WithPosixFS(posixfs.NewOSFS()))
+	require.NoError(t, err)
+	require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader(strings.Repeat("version 1 ", 1000))))
+	require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader("version 2")))
+	// a nested volume, sharing the root volume's store.
+	nested := newTestVolumeSpec(t)
+	nested.Store = spec.Store
+	putVolumeSpec(t, wfs, "vol.webfs", nested)
+	require.NoError(t, wfs.PutFile(ctx, "vol/b.txt", strings.NewReader("nested")))
+
+	before := countBlobs(t, wfs.root.vol.Store)
+	res, err := wfs.GC(ctx, GCDryRun())
+	require.NoError(t, err)
+	require.Greater(t, res.Unreachable, 0)
+	require.Greater(t, res.UnreachableBytes, int64(len("version 1 ")*1000)-1)
+	require.Equal(t, before, res.Reachable+res.Unreachable)
+	require.Equal(t, before, countBlobs(t, wfs.root.vol.Store))
+
+	res2, err := wfs.GC(ctx)
+	require.NoError(t, err)
+	require.Equal(t, res.Reachable, res2.Reachable)
+	require.Equal(t, res.Unreachable, res2.Unreachable)
+	// sizes are only read by a dry run.
+	require.Zero(t, res2.UnreachableBytes)
+	require.Equal(t, res.Reachable, countBlobs(t, wfs.root.vol.Store))
+	require.Equal(t, "version 2", catString(t, wfs, "a.txt"))
+	require.Equal(t, "nested", catString(t, wfs, "vol/b.txt"))
+
+	res, err = wfs.GC(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, res.Unreachable)
+}
+
+func TestGCHistory(t *testing.T) {
+	ctx := context.Background()
+	spec := newTestVolumeSpec(t)
+	spec.History = true
+	wfs, err := New(spec, WithPosixFS(posixfs.NewOSFS()))
+	require.NoError(t, err)
+	require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader("version 1")))
+	require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader("version 2")))
+	_, err = wfs.GC(ctx)
+	require.NoError(t, err)
+
+	versions, err := wfs.History(ctx, "a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	f, err := wfs.OpenAt(ctx, "a.txt", versions[1].N)
+	require.NoError(t, err)
+	require.Equal(t, "version 1", readAll(t, f))
+}
+
+func countBlobs(t testing.TB, s cadata.Store) (count int) {
+	require.NoError(t, cadata.ForEach(context.Background(), s, state.TotalSpan[cadata.ID](), func(cadata.ID) error {
+		count++
+		return nil
+	}))
+	return count
+}
@@ -0,0 +1,191 @@
+package webfs
+
+import (
+	"context"
+	"fmt"
+	iofs "io/fs"
+	"time"
+
+	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/gotvc/got/pkg/gotfs"
+	"github.com/gotvc/got/pkg/gotvc"
+
+	"github.com/brendoncarroll/webfs/pkg/cells/gotcells"
+)
+
+// Version is a version of a file or directory in a volume which keeps history.
+type Version struct {
+	// N identifies the version within the volume's history, larger is newer.
+	N         uint64
+	CreatedAt time.Time
+	// Deleted is true if the path did not exist in this version.
+	Deleted bool
+}
+
+// History returns the versions of the volume containing p in which p was changed, newest first.
+// The volume must keep history, see VolumeSpec.History.
+func (fs *FS) History(ctx context.Context, p string) ([]Version, error) {
+	res, err := fs.resolve(ctx, fs.root, p)
+	if err != nil {
+		return nil, err
+	}
+	var ret []Version
+	var prevRoot *gotfs.Root
+	var prevVersion *Version
+	if err := res.VM.forEachSnapshot(ctx, func(snap gotvc.Snapshot) error {
+		root := snapshotRoot(snap)
+		info, err := res.VM.getInfo(ctx, root, res.Path)
+		if err != nil {
+			return err
+		}
+		exists := info != nil
+		if prevVersion != nil {
+			same := exists == !prevVersion.Deleted
+			if same && exists {
+				if same, err = res.VM.sameTree(ctx, *prevRoot, *root, res.Path); err != nil {
+					return err
+				}
+			}
+			if !same {
+				ret = append(ret, *prevVersion)
+			}
+		}
+		prevRoot = root
+		prevVersion = &Version{
+			N:         snap.N,
+			CreatedAt: snap.CreatedAt.GoTime(),
+			Deleted:   !exists,
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	// the oldest version is a change if it created p.
+	if prevVersion != nil && !prevVersion.Deleted {
+		ret = append(ret, *prevVersion)
+	}
+	return ret, nil
+}
+
+// OpenAt opens the file or directory at p for reading, as it was in version.
+func (fs *FS) OpenAt(ctx context.Context, p string, version uint64) (*File, error) {
+	res, err := fs.resolve(ctx, fs.root, p)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := res.VM.getSnapshot(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	root := snapshotRoot(*snap)
+	if root == nil {
+		return nil, iofs.ErrNotExist
+	}
+	if _, err := res.VM.gotfs.GetInfo(ctx, res.VM.vol.Store, *root, res.Path); err != nil {
+		return nil, convertError(err)
+	}
+	return newFile(ctx, res.VM, root, res.Path), nil
+}
+
+// Restore replaces the file or directory at p with the one that was there in version.
+// If p did not exist in version, it is removed.
+// Restoring creates a new version, so it can also be undone.
+func (fs *FS) Restore(ctx context.Context, p string, version uint64) error {
+	res, err := fs.resolve(ctx, fs.root, p)
+	if err != nil {
+		return err
+	}
+	v := res.VM
+	snap, err := v.getSnapshot(ctx, version)
+	if err != nil {
+		return err
+	}
+	ms, ds := v.vol.Store, v.vol.Store
+	var branch *gotfs.Root
+	if root := snapshotRoot(*snap); root != nil {
+		if _, err := v.gotfs.GetInfo(ctx, ms, *root, res.Path); err == nil {
+			if branch, err = selectBranch(ctx, &v.gotfs, ms, ds, *root, res.Path); err != nil {
+				return err
+			}
+		} else if !posixfs.IsErrNotExist(err) {
+			return err
+		}
+	}
+	return v.modifyRoot(ctx, res.Path, func(root *gotfs.Root) (*gotfs.Root, error) {
+		var err error
+		if root == nil {
+			if root, err = v.gotfs.NewEmpty(ctx, ms); err != nil {
+				return nil, err
+			}
+		}
+		now := time.Now()
+		if branch == nil {
+			return v.remove(ctx, *root, res.Path, now)
+		}
+		return v.place(ctx, *root, res.Path, *branch, now)
+	})
+}
+
+// branchCell returns the cell holding the volume's history, or ErrNoHistory.
+func (v *volumeMount) branchCell() (*gotcells.BranchCell, error) {
+	bc, ok := v.vol.Cell.(*gotcells.BranchCell)
+	if !ok {
+		return nil, ErrNoHistory
+	}
+	return bc, nil
+}
+
+// forEachSnapshot calls fn with each snapshot in the volume's history, starting from the latest
+// and following the first parent of each snapshot.
+func (v *volumeMount) forEachSnapshot(ctx context.Context, fn func(gotvc.Snapshot) error) error {
+	bc, err := v.branchCell()
+	if err != nil {
+		return err
+	}
+	snap, err := bc.Head(ctx)
+	if err != nil {
+		return err
+	}
+	vcop := gotvc.NewOperator()
+	for snap != nil {
+		if err := fn(*snap); err != nil {
+			return err
+		}
+		if len(snap.Parents) == 0 {
+			break
+		}
+		if snap, err = vcop.GetSnapshot(ctx, bc.VCStore(), snap.Parents[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSnapshot returns the snapshot for version n.
+func (v *volumeMount) getSnapshot(ctx context.Context, n uint64) (*gotvc.Snapshot, error) {
+	var ret *gotvc.Snapshot
+	if err := v.forEachSnapshot(ctx, func(snap gotvc.Snapshot) error {
+		if snap.N == n {
+			ret = &snap
+			return errStopIter
+		}
+		if snap.N < n {
+			return errStopIter
+		}
+		return nil
+	}); err != nil && err != errStopIter {
+		return nil, err
+	}
+	if ret == nil {
+		return nil, fmt.Errorf("no version %d in history", n)
+	}
+	return ret, nil
+}
+
+// snapshotRoot returns the root of the volume in snap, or nil if the volume was empty.
+func snapshotRoot(snap gotvc.Snapshot) *gotfs.Root {
+	if gotcells.IsEmpty(snap) {
+		return nil
+	}
+	return &snap.Root
+}
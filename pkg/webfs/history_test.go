@@ -0,0 +1,80 @@
+package webfs
+
+import (
+	"bytes"
+	"context"
+	iofs "io/fs"
+	"strings"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/cells"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	spec := newTestVolumeSpec(t)
+	spec.History = true
+	putVolumeSpec(t, wfs, "vol.webfs", spec)
+
+	require.NoError(t, wfs.PutFile(ctx, "vol/a.txt", strings.NewReader("version 1")))
+	require.NoError(t, wfs.PutFile(ctx, "vol/b.txt", strings.NewReader("unrelated")))
+	require.NoError(t, wfs.PutFile(ctx, "vol/a.txt", strings.NewReader("version 2")))
+	require.NoError(t, wfs.Remove(ctx, "vol/a.txt"))
+
+	versions, err := wfs.History(ctx, "vol/a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.True(t, versions[0].Deleted)
+	require.False(t, versions[1].Deleted)
+	require.False(t, versions[2].Deleted)
+	require.Greater(t, versions[1].N, versions[2].N)
+
+	f, err := wfs.OpenAt(ctx, "vol/a.txt", versions[2].N)
+	require.NoError(t, err)
+	require.Equal(t, "version 1", readAll(t, f))
+	_, err = wfs.OpenAt(ctx, "vol/a.txt", versions[0].N)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+
+	require.NoError(t, wfs.Restore(ctx, "vol/a.txt", versions[1].N))
+	require.Equal(t, "version 2", catString(t, wfs, "vol/a.txt"))
+	require.Equal(t, "unrelated", catString(t, wfs, "vol/b.txt"))
+	// restoring is a change too.
+	versions2, err := wfs.History(ctx, "vol/a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions2, 4)
+
+	// volumes without history don't have versions.
+	_, err = wfs.History(ctx, "b.txt")
+	require.ErrorIs(t, err, ErrNoHistory)
+	require.ErrorIs(t, wfs.Cat(ctx, "vol/missing", &bytes.Buffer{}), iofs.ErrNotExist)
+}
+
+func TestHistoryEmptied(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	spec := newTestVolumeSpec(t)
+	spec.History = true
+	putVolumeSpec(t, wfs, "vol.webfs", spec)
+	require.NoError(t, wfs.PutFile(ctx, "vol/a.txt", strings.NewReader("version 1")))
+	res, err := wfs.resolve(ctx, wfs.root, "vol")
+	require.NoError(t, err)
+	require.NoError(t, cells.Apply(ctx, res.VM.vol.Cell, func([]byte) ([]byte, error) {
+		return nil, nil
+	}))
+	require.NoError(t, wfs.PutFile(ctx, "vol/a.txt", strings.NewReader("version 2")))
+
+	// the version in which the volume was emptied is kept, along with the ones before it.
+	versions, err := wfs.History(ctx, "vol/a.txt")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.True(t, versions[1].Deleted)
+	_, err = wfs.OpenAt(ctx, "vol/a.txt", versions[1].N)
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+	f, err := wfs.OpenAt(ctx, "vol/a.txt", versions[2].N)
+	require.NoError(t, err)
+	require.Equal(t, "version 1", readAll(t, f))
+	_, err = wfs.GC(ctx)
+	require.NoError(t, err)
+}
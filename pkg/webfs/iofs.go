@@ -0,0 +1,138 @@
+package webfs
+
+import (
+	"context"
+	"io"
+	iofs "io/fs"
+	"path"
+	"sort"
+)
+
+var (
+	_ iofs.StatFS     = &ioFS{}
+	_ iofs.ReadFileFS = &ioFS{}
+	_ iofs.ReadDirFS  = &ioFS{}
+	_ iofs.SubFS      = &ioFS{}
+	_ iofs.GlobFS     = &ioFS{}
+)
+
+// IOFS returns a view of fs which implements the interfaces in io/fs.
+// All operations performed through it use ctx.
+func (fs *FS) IOFS(ctx context.Context) iofs.FS {
+	return &ioFS{ctx: ctx, fs: fs}
+}
+
+type ioFS struct {
+	ctx context.Context
+	fs  *FS
+	// prefix is the directory the view is rooted at, see Sub.
+	prefix string
+}
+
+func (f *ioFS) Open(name string) (iofs.File, error) {
+	p, err := f.join("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := f.fs.Open(f.ctx, p)
+	if err != nil {
+		return nil, wrapPathError("open", name, err)
+	}
+	return file, nil
+}
+
+func (f *ioFS) Stat(name string) (iofs.FileInfo, error) {
+	p, err := f.join("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	finfo, err := f.fs.Stat(f.ctx, p)
+	if err != nil {
+		return nil, wrapPathError("stat", name, err)
+	}
+	return finfo, nil
+}
+
+func (f *ioFS) ReadFile(name string) ([]byte, error) {
+	p, err := f.join("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := f.fs.Open(f.ctx, p)
+	if err != nil {
+		return nil, wrapPathError("readfile", name, err)
+	}
+	defer file.Close()
+	finfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if finfo.IsDir() {
+		return nil, &iofs.PathError{Op: "readfile", Path: name, Err: iofs.ErrInvalid}
+	}
+	buf := make([]byte, 0, finfo.Size())
+	for {
+		if len(buf) == cap(buf) {
+			buf = append(buf, 0)[:len(buf)]
+		}
+		n, err := file.ReadAt(buf[len(buf):cap(buf)], int64(len(buf)))
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			return buf, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReadDir returns the entries of the directory name, sorted by filename.
+func (f *ioFS) ReadDir(name string) ([]iofs.DirEntry, error) {
+	p, err := f.join("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := f.fs.Open(f.ctx, p)
+	if err != nil {
+		return nil, wrapPathError("readdir", name, err)
+	}
+	defer file.Close()
+	ents, err := file.ReadDir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ents, func(i, j int) bool {
+		return ents[i].Name() < ents[j].Name()
+	})
+	return ents, nil
+}
+
+func (f *ioFS) Sub(dir string) (iofs.FS, error) {
+	p, err := f.join("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	return &ioFS{ctx: f.ctx, fs: f.fs, prefix: p}, nil
+}
+
+func (f *ioFS) Glob(pattern string) ([]string, error) {
+	// hide this method from iofs.Glob, so it walks the directories using ReadDir instead of calling back here.
+	return iofs.Glob(struct{ iofs.ReadDirFS }{f}, pattern)
+}
+
+// join validates name and returns the path within fs that it refers to.
+func (f *ioFS) join(op, name string) (string, error) {
+	if !iofs.ValidPath(name) {
+		return "", &iofs.PathError{Op: op, Path: name, Err: iofs.ErrInvalid}
+	}
+	if name == "." {
+		return f.prefix, nil
+	}
+	return path.Join(f.prefix, name), nil
+}
+
+func wrapPathError(op, name string, err error) error {
+	if _, ok := err.(*iofs.PathError); ok {
+		return err
+	}
+	return &iofs.PathError{Op: op, Path: name, Err: err}
+}
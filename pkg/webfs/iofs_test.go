@@ -0,0 +1,32 @@
+package webfs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIOFS(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	files := map[string]string{
+		"a.txt":         "aaaa",
+		"b/c.txt":       "cccc",
+		"b/d/e.txt":     strings.Repeat("e", 1000),
+		"b/d/f.txt":     "",
+		"b/g/h.txt":     "hhhh",
+		"empty/.keep":   "",
+		"x/y/z/last.md": "last",
+	}
+	for p, data := range files {
+		require.NoError(t, wfs.PutFile(ctx, p, strings.NewReader(data)))
+	}
+	var expected []string
+	for p := range files {
+		expected = append(expected, p)
+	}
+	require.NoError(t, fstest.TestFS(wfs.IOFS(ctx), expected...))
+}
@@ -0,0 +1,519 @@
+package webfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"sort"
+	"strings"
+
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/gotvc/got/pkg/gotfs"
+	"github.com/gotvc/got/pkg/gotkv"
+	"golang.org/x/crypto/blake2b"
+	"google.golang.org/protobuf/proto"
+)
+
+// ConflictPolicy decides what happens when concurrent writers change the same path in a volume.
+type ConflictPolicy int
+
+const (
+	// LastWriterWins keeps the change made by the writer which commits last.
+	LastWriterWins ConflictPolicy = iota
+	// KeepBoth keeps the change which was committed first at the path,
+	// and puts the later change next to it, at ConflictPath(path).
+	KeepBoth
+	// FailOnConflict rejects the later change with an ErrConflict.
+	FailOnConflict
+)
+
+// ConflictSuffix ends the name of the later change when using KeepBoth.
+const ConflictSuffix = ".conflict"
+
+// ConflictPath returns the path where KeepBoth puts the later of two conflicting changes to p.
+//
+// gotfs requires that no name in a directory is another name followed by a character sorting
+// before '/', so the suffix is preceded by a '~', e.g. "notes.txt" conflicts go to "notes.txt~.conflict".
+func ConflictPath(p string) string {
+	return cleanPath(p) + "~" + ConflictSuffix
+}
+
+var conflictPolicyNames = map[ConflictPolicy]string{
+	LastWriterWins: "last-writer-wins",
+	KeepBoth:       "keep-both",
+	FailOnConflict: "fail",
+}
+
+func (p ConflictPolicy) String() string {
+	if name, ok := conflictPolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("ConflictPolicy(%d)", int(p))
+}
+
+// ParseConflictPolicy parses one of "last-writer-wins", "keep-both" or "fail".
+func ParseConflictPolicy(x string) (ConflictPolicy, error) {
+	for p, name := range conflictPolicyNames {
+		if x == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown conflict policy %q", x)
+}
+
+// merge combines the changes made from base to ours with the changes made from base to theirs.
+// Changes to different paths are all kept. When both sides change the same path differently,
+// the volume's ConflictPolicy decides the outcome.
+// Directories changed on both sides are not conflicts, their entries are merged individually.
+// touched are the paths we changed, along with anything below them, see changedPaths.
+func (v *volumeMount) merge(ctx context.Context, base, ours, theirs *gotfs.Root, touched []string) (*gotfs.Root, error) {
+	if rootsEqual(base, ours) {
+		return theirs, nil
+	}
+	ms, ds := v.vol.Store, v.vol.Store
+	// paths we did not change keep their version in theirs, so only our changes are looked at.
+	paths, err := v.changedPaths(ctx, base, ours, touched)
+	if err != nil {
+		return nil, err
+	}
+
+	x := theirs
+	if x == nil {
+		if x, err = v.gotfs.NewEmpty(ctx, ms); err != nil {
+			return nil, err
+		}
+	}
+	// done holds paths which have been replaced along with everything below them.
+	var done []string
+	for _, p := range paths {
+		if isBelowAny(p, done) {
+			continue
+		}
+		b, err := v.getInfo(ctx, base, p)
+		if err != nil {
+			return nil, err
+		}
+		o, err := v.getInfo(ctx, ours, p)
+		if err != nil {
+			return nil, err
+		}
+		t, err := v.getInfo(ctx, theirs, p)
+		if err != nil {
+			return nil, err
+		}
+		if same, err := v.sameEntry(ctx, base, ours, p, b, o); err != nil {
+			return nil, err
+		} else if same {
+			// only they changed p, which is already in x.
+			continue
+		}
+		if same, err := v.sameEntry(ctx, base, theirs, p, b, t); err != nil {
+			return nil, err
+		} else if !same {
+			// both sides changed p.
+			if o != nil && t != nil && o.Mode == t.Mode {
+				if iofs.FileMode(o.Mode).IsDir() {
+					if getModTime(o).After(getModTime(t)) {
+						if x, err = v.gotfs.PutInfo(ctx, ms, *x, p, o); err != nil {
+							return nil, err
+						}
+					}
+					continue
+				}
+				if same, err := v.sameContent(ctx, *ours, *theirs, p); err != nil {
+					return nil, err
+				} else if same {
+					continue
+				}
+			}
+			switch v.conflicts {
+			case FailOnConflict:
+				return nil, ErrConflict{Path: p}
+			case KeepBoth:
+				if o == nil {
+					// there is nothing of ours to keep.
+					continue
+				}
+				if t != nil {
+					branch, err := selectBranch(ctx, &v.gotfs, ms, ds, *ours, p)
+					if err != nil {
+						return nil, err
+					}
+					if x, err = v.replace(ctx, *x, ConflictPath(p), *branch); err != nil {
+						return nil, err
+					}
+					done = append(done, p)
+					continue
+				}
+			}
+		}
+		// apply our change to p.
+		switch {
+		case o == nil:
+			if x, err = v.gotfs.RemoveAll(ctx, ms, *x, p); err != nil {
+				return nil, err
+			}
+			done = append(done, p)
+		case t != nil && iofs.FileMode(o.Mode).IsDir() && iofs.FileMode(t.Mode).IsDir():
+			if x, err = v.gotfs.PutInfo(ctx, ms, *x, p, o); err != nil {
+				return nil, err
+			}
+		default:
+			branch, err := selectBranch(ctx, &v.gotfs, ms, ds, *ours, p)
+			if err != nil {
+				return nil, err
+			}
+			if x, err = v.replace(ctx, *x, p, *branch); err != nil {
+				return nil, err
+			}
+			done = append(done, p)
+		}
+	}
+	return x, nil
+}
+
+// replace puts branch at p, replacing anything already there.
+// Unlike place, it does not create missing parents or change any modification times.
+func (v *volumeMount) replace(ctx context.Context, root gotfs.Root, p string, branch gotfs.Root) (*gotfs.Root, error) {
+	ms, ds := v.vol.Store, v.vol.Store
+	x, err := v.gotfs.RemoveAll(ctx, ms, root, p)
+	if err != nil {
+		return nil, err
+	}
+	return v.gotfs.Graft(ctx, ms, ds, *x, p, branch)
+}
+
+// getInfo returns the info for p in root, or nil if p does not exist.
+func (v *volumeMount) getInfo(ctx context.Context, root *gotfs.Root, p string) (*gotfs.Info, error) {
+	if root == nil {
+		return nil, nil
+	}
+	info, err := v.gotfs.GetInfo(ctx, v.vol.Store, *root, p)
+	if posixfs.IsErrNotExist(err) {
+		return nil, nil
+	}
+	return info, err
+}
+
+// changedPaths returns the paths which have different entries in a and b, with directories before their contents.
+// Only the entries at and below each of touched, and those of the directories containing them, are compared,
+// so touched must include every path which was changed, apart from those directories.
+// A nil root is the same as an empty one.
+func (v *volumeMount) changedPaths(ctx context.Context, a, b *gotfs.Root, touched []string) ([]string, error) {
+	var spans []gotkv.Span
+	var subtrees []string
+	for _, p := range touched {
+		p = cleanPath(p)
+		subtrees = append(subtrees, p)
+		for q := p; q != ""; {
+			q = parentOf(q)
+			spans = append(spans, gotkv.SingleKeySpan(gotfs.SpanForPath(q).Begin))
+		}
+	}
+	sort.Strings(subtrees)
+	var covered []string
+	for _, p := range subtrees {
+		if (len(covered) > 0 && covered[len(covered)-1] == p) || isBelowAny(p, covered) {
+			continue
+		}
+		covered = append(covered, p)
+		spans = append(spans, gotfs.SpanForPath(p))
+	}
+
+	changed := map[string]struct{}{}
+	for _, span := range spans {
+		if err := v.diffSpan(ctx, a, b, span, func(key []byte) {
+			changed[entryPath(key)] = struct{}{}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	ret := make([]string, 0, len(changed))
+	for p := range changed {
+		ret = append(ret, p)
+	}
+	// sorting by key puts directories before their contents, as gotfs does.
+	sort.Slice(ret, func(i, j int) bool {
+		return bytes.Compare(gotfs.SpanForPath(ret[i]).Begin, gotfs.SpanForPath(ret[j]).Begin) < 0
+	})
+	return ret, nil
+}
+
+// diffSpan calls fn with the key of each entry in span which is different in a and b.
+func (v *volumeMount) diffSpan(ctx context.Context, a, b *gotfs.Root, span gotkv.Span, fn func(key []byte)) error {
+	sa, err := v.newEntryStream(ctx, a, span)
+	if err != nil {
+		return err
+	}
+	sb, err := v.newEntryStream(ctx, b, span)
+	if err != nil {
+		return err
+	}
+	for sa.ok || sb.ok {
+		var cmp int
+		switch {
+		case !sa.ok:
+			cmp = 1
+		case !sb.ok:
+			cmp = -1
+		default:
+			cmp = bytes.Compare(sa.ent.Key, sb.ent.Key)
+		}
+		if cmp < 0 || cmp == 0 && !bytes.Equal(sa.ent.Value, sb.ent.Value) {
+			fn(sa.ent.Key)
+		} else if cmp > 0 {
+			fn(sb.ent.Key)
+		}
+		if cmp <= 0 {
+			if err := sa.next(ctx); err != nil {
+				return err
+			}
+		}
+		if cmp >= 0 {
+			if err := sb.next(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entryStream reads the raw gotfs entries in a span of a root in order.
+type entryStream struct {
+	it gotkv.Iterator
+	// ent is the current entry, if ok is true.
+	ent gotkv.Entry
+	ok  bool
+}
+
+func (v *volumeMount) newEntryStream(ctx context.Context, root *gotfs.Root, span gotkv.Span) (*entryStream, error) {
+	s := &entryStream{}
+	if root == nil {
+		return s, nil
+	}
+	s.it = v.gotkv.NewIterator(v.vol.Store, *root, span)
+	return s, s.next(ctx)
+}
+
+func (s *entryStream) next(ctx context.Context) error {
+	s.ok = false
+	if s.it == nil {
+		return nil
+	}
+	if err := s.it.Next(ctx, &s.ent); errors.Is(err, gotkv.EOS) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	s.ok = true
+	return nil
+}
+
+// sameEntry returns true if p has the same info and contents in a and b.
+// ia and ib are the infos for p in a and b, nil if p does not exist.
+func (v *volumeMount) sameEntry(ctx context.Context, a, b *gotfs.Root, p string, ia, ib *gotfs.Info) (bool, error) {
+	if ia == nil || ib == nil {
+		return ia == nil && ib == nil, nil
+	}
+	if ia.Mode != ib.Mode || !labelsEqual(ia.Labels, ib.Labels) {
+		return false, nil
+	}
+	if !iofs.FileMode(ia.Mode).IsRegular() {
+		return true, nil
+	}
+	return v.sameContent(ctx, *a, *b, p)
+}
+
+// sameContent returns true if the file at p has the same contents in a and b.
+func (v *volumeMount) sameContent(ctx context.Context, a, b gotfs.Root, p string) (bool, error) {
+	// identical extents always have identical contents, but the same contents
+	// may be stored in different extents, so fall back to comparing the data.
+	ea, err := v.listExtents(ctx, a, p)
+	if err != nil {
+		return false, err
+	}
+	eb, err := v.listExtents(ctx, b, p)
+	if err != nil {
+		return false, err
+	}
+	if entriesEqual(ea, eb) {
+		return true, nil
+	}
+	ha, err := v.hashContent(ctx, a, p)
+	if err != nil {
+		return false, err
+	}
+	hb, err := v.hashContent(ctx, b, p)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ha, hb), nil
+}
+
+// sameTree returns true if everything at p is the same in a and b.
+// Only the metadata is compared, unless the extents of a file differ, see sameContent.
+func (v *volumeMount) sameTree(ctx context.Context, a, b gotfs.Root, p string) (bool, error) {
+	ea, err := v.listExtents(ctx, a, p)
+	if err != nil {
+		return false, err
+	}
+	eb, err := v.listExtents(ctx, b, p)
+	if err != nil {
+		return false, err
+	}
+	if entriesEqual(ea, eb) {
+		return true, nil
+	}
+	ga, gb := groupEntries(ea), groupEntries(eb)
+	if len(ga) != len(gb) {
+		return false, nil
+	}
+	for i := range ga {
+		if !bytes.Equal(ga[i].info.Key, gb[i].info.Key) || !bytes.Equal(ga[i].info.Value, gb[i].info.Value) {
+			return false, nil
+		}
+		if entriesEqual(ga[i].extents, gb[i].extents) {
+			continue
+		}
+		same, err := v.sameContent(ctx, a, b, strings.Trim(string(ga[i].info.Key), "/"))
+		if err != nil || !same {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// entryGroup is the info entry for a path, followed by the entries for its extents.
+type entryGroup struct {
+	info    gotkv.Entry
+	extents []gotkv.Entry
+}
+
+// groupEntries splits the raw gotfs entries ents, as returned by listExtents, by path.
+func groupEntries(ents []gotkv.Entry) (ret []entryGroup) {
+	for _, ent := range ents {
+		if _, ok := parseExtentKey(ent.Key); ok && len(ret) > 0 {
+			last := &ret[len(ret)-1]
+			last.extents = append(last.extents, ent)
+			continue
+		}
+		ret = append(ret, entryGroup{info: ent})
+	}
+	return ret
+}
+
+// listExtents returns the raw gotkv entries for the file at p, or for everything in the directory at p.
+func (v *volumeMount) listExtents(ctx context.Context, root gotfs.Root, p string) (ret []gotkv.Entry, _ error) {
+	if err := v.gotkv.ForEach(ctx, v.vol.Store, root, gotfs.SpanForPath(p), func(ent gotkv.Entry) error {
+		ret = append(ret, gotkv.Entry{
+			Key:   append([]byte{}, ent.Key...),
+			Value: append([]byte{}, ent.Value...),
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// forEachExtent calls fn with each extent of the file at p in order, along with the range of the file it contains.
+// The metadata is read from s, which must hold the same blobs as the volume's store.
+func (v *volumeMount) forEachExtent(ctx context.Context, s cadata.Store, root gotfs.Root, p string, fn func(begin, end int64, ext *gotfs.Extent) error) error {
+	return v.gotkv.ForEach(ctx, s, root, extentSpan(p), func(ent gotkv.Entry) error {
+		end, ok := parseExtentKey(ent.Key)
+		if !ok {
+			return nil
+		}
+		ext := &gotfs.Extent{}
+		if err := proto.Unmarshal(ent.Value, ext); err != nil {
+			return err
+		}
+		return fn(int64(end)-int64(ext.Length), int64(end), ext)
+	})
+}
+
+// extentSpan returns the span containing the extent keys of the file at p.
+// gotfs stores extents under the info key for their file, followed by 0x00 and the big-endian end offset.
+func extentSpan(p string) gotkv.Span {
+	infoKey := gotfs.SpanForPath(p).Begin
+	return gotkv.Span{
+		Begin: append(append([]byte{}, infoKey...), 0x00),
+		End:   append(append([]byte{}, infoKey...), 0x01),
+	}
+}
+
+// entryPath returns the path of the file or directory which the gotfs entry with key k belongs to.
+func entryPath(k []byte) string {
+	if _, ok := parseExtentKey(k); ok {
+		k = k[:len(k)-9]
+	}
+	return strings.Trim(string(k), "/")
+}
+
+// parseExtentKey returns the end offset of the extent with key k.
+// ok is false if k is not an extent key, paths never contain 0x00 so info keys never do.
+func parseExtentKey(k []byte) (end uint64, ok bool) {
+	i := bytes.IndexByte(k, 0x00)
+	if i < 0 || len(k)-i != 9 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(k[i+1:]), true
+}
+
+// hashContent returns a hash of the contents of the file at p.
+func (v *volumeMount) hashContent(ctx context.Context, root gotfs.Root, p string) ([]byte, error) {
+	h, _ := blake2b.New256(nil)
+	if _, err := io.Copy(h, v.gotfs.NewReader(ctx, v.vol.Store, v.vol.Store, root, p)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func rootsEqual(a, b *gotfs.Root) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	aData, _ := json.Marshal(a)
+	bData, _ := json.Marshal(b)
+	return bytes.Equal(aData, bData)
+}
+
+func entriesEqual(a, b []gotkv.Entry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].Key, b[i].Key) || !bytes.Equal(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if v2, ok := b[k]; !ok || v2 != v {
+			return false
+		}
+	}
+	return true
+}
+
+// isBelowAny returns true if p is strictly within any of the directories in dirs.
+func isBelowAny(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if dir == "" || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
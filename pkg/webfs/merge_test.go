@@ -0,0 +1,219 @@
+package webfs
+
+import (
+	"bytes"
+	"context"
+	iofs "io/fs"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/cells"
+	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/gotvc/got/pkg/gotfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeDifferentPaths(t *testing.T) {
+	ctx := context.Background()
+	wfs, cell := newRacingWebFS(t, LastWriterWins)
+	require.NoError(t, wfs.PutFile(ctx, "dir/base.txt", strings.NewReader("base")))
+
+	cell.race = func() {
+		require.NoError(t, wfs.PutFile(ctx, "dir/theirs.txt", strings.NewReader("theirs")))
+		require.NoError(t, wfs.Remove(ctx, "dir/base.txt"))
+	}
+	require.NoError(t, wfs.PutFile(ctx, "dir/ours.txt", strings.NewReader("ours")))
+
+	require.Equal(t, "theirs", catString(t, wfs, "dir/theirs.txt"))
+	require.Equal(t, "ours", catString(t, wfs, "dir/ours.txt"))
+	_, err := wfs.Stat(ctx, "dir/base.txt")
+	require.True(t, posixfs.IsErrNotExist(err))
+}
+
+func TestMergeRename(t *testing.T) {
+	ctx := context.Background()
+	wfs, cell := newRacingWebFS(t, LastWriterWins)
+	require.NoError(t, wfs.PutFile(ctx, "dir/base.txt", strings.NewReader("base")))
+
+	// both the source and the destination of a rename are merged.
+	cell.race = func() {
+		require.NoError(t, wfs.PutFile(ctx, "dir/theirs.txt", strings.NewReader("theirs")))
+	}
+	require.NoError(t, wfs.Rename(ctx, "dir/base.txt", "other/moved.txt"))
+
+	require.Equal(t, "theirs", catString(t, wfs, "dir/theirs.txt"))
+	require.Equal(t, "base", catString(t, wfs, "other/moved.txt"))
+	_, err := wfs.Stat(ctx, "dir/base.txt")
+	require.True(t, posixfs.IsErrNotExist(err))
+}
+
+func TestMergeConflict(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		policy   ConflictPolicy
+		expected string
+		conflict string
+		err      error
+	}{
+		{policy: LastWriterWins, expected: "ours"},
+		{policy: KeepBoth, expected: "theirs", conflict: "ours"},
+		{policy: FailOnConflict, expected: "theirs", err: ErrConflict{Path: "a.txt"}},
+	} {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			wfs, cell := newRacingWebFS(t, tc.policy)
+			require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader("base")))
+			require.NoError(t, wfs.PutFile(ctx, "b.txt", strings.NewReader("base")))
+
+			cell.race = func() {
+				require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader("theirs")))
+			}
+			err := wfs.PutFile(ctx, "a.txt", strings.NewReader("ours"))
+			if tc.err != nil {
+				require.Equal(t, tc.err, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.expected, catString(t, wfs, "a.txt"))
+			require.Equal(t, "base", catString(t, wfs, "b.txt"))
+			if tc.conflict != "" {
+				require.Equal(t, tc.conflict, catString(t, wfs, ConflictPath("a.txt")))
+			} else {
+				_, err := wfs.Stat(ctx, ConflictPath("a.txt"))
+				require.True(t, posixfs.IsErrNotExist(err))
+			}
+			// the volume can still be changed afterwards.
+			require.NoError(t, wfs.PutFile(ctx, "c.txt", strings.NewReader("after")))
+		})
+	}
+}
+
+func TestMergeSameContent(t *testing.T) {
+	ctx := context.Background()
+	wfs, cell := newRacingWebFS(t, FailOnConflict)
+	cell.race = func() {
+		require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader("same")))
+	}
+	require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader("same")))
+	require.Equal(t, "same", catString(t, wfs, "a.txt"))
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	for _, p := range []ConflictPolicy{LastWriterWins, KeepBoth, FailOnConflict} {
+		p2, err := ParseConflictPolicy(p.String())
+		require.NoError(t, err)
+		require.Equal(t, p, p2)
+	}
+	_, err := ParseConflictPolicy("")
+	require.Error(t, err)
+}
+
+func TestMergeExclusiveCreate(t *testing.T) {
+	ctx := context.Background()
+	wfs, cell := newRacingWebFS(t, LastWriterWins)
+	cell.race = func() {
+		require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader("theirs")))
+	}
+	_, err := wfs.OpenFile(ctx, "a.txt", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	require.ErrorIs(t, err, iofs.ErrExist)
+	require.Equal(t, "theirs", catString(t, wfs, "a.txt"))
+
+	// a concurrent change to another path does not stop the file being created.
+	cell.race = func() {
+		require.NoError(t, wfs.PutFile(ctx, "b.txt", strings.NewReader("theirs")))
+	}
+	f, err := wfs.OpenFile(ctx, "c.txt", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "theirs", catString(t, wfs, "b.txt"))
+	require.Equal(t, "", catString(t, wfs, "c.txt"))
+}
+
+func TestChangedPaths(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	v := wfs.root
+	require.NoError(t, wfs.PutFile(ctx, "a", strings.NewReader("a")))
+	require.NoError(t, wfs.PutFile(ctx, "dir/b", strings.NewReader("b")))
+	base, err := readRoot(ctx, v.vol.Cell)
+	require.NoError(t, err)
+	require.NoError(t, wfs.PutFile(ctx, "dir/b", strings.NewReader("b2")))
+	require.NoError(t, wfs.Remove(ctx, "a"))
+	ours, err := readRoot(ctx, v.vol.Cell)
+	require.NoError(t, err)
+
+	paths, err := v.changedPaths(ctx, base, ours, []string{"dir/b", "a"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"", "a", "dir", "dir/b"}, paths)
+	paths, err = v.changedPaths(ctx, nil, base, []string{""})
+	require.NoError(t, err)
+	require.Equal(t, []string{"", "a", "dir", "dir/b"}, paths)
+	paths, err = v.changedPaths(ctx, ours, ours, []string{""})
+	require.NoError(t, err)
+	require.Empty(t, paths)
+	// only the touched paths and the directories containing them are compared.
+	paths, err = v.changedPaths(ctx, base, ours, []string{"a"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"", "a"}, paths)
+	paths, err = v.changedPaths(ctx, base, ours, []string{"dir", "dir/b"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"", "dir", "dir/b"}, paths)
+}
+
+// TestExtentKeys fails if gotfs changes the layout of the keys for extents, which webfs reads directly.
+func TestExtentKeys(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	data := make([]byte, 3*gotfs.DefaultAverageBlobSizeData)
+	rand.New(rand.NewSource(0)).Read(data)
+	require.NoError(t, wfs.PutFile(ctx, "dir/test", bytes.NewReader(data)))
+	require.NoError(t, wfs.PutFile(ctx, "dir/test2", strings.NewReader("after")))
+
+	v := wfs.root
+	root, err := readRoot(ctx, v.vol.Cell)
+	require.NoError(t, err)
+	ents, err := v.listExtents(ctx, *root, "dir/test")
+	require.NoError(t, err)
+	_, ok := parseExtentKey(ents[0].Key)
+	require.False(t, ok, "the first entry is the file's info")
+
+	var count int
+	var offset int64
+	require.NoError(t, v.forEachExtent(ctx, v.vol.Store, *root, "dir/test", func(begin, end int64, ext *gotfs.Extent) error {
+		require.Equal(t, offset, begin)
+		require.Equal(t, int64(ext.Length), end-begin)
+		count++
+		offset = end
+		return nil
+	}))
+	require.Greater(t, count, 1)
+	require.Equal(t, len(ents)-1, count)
+	require.Equal(t, int64(len(data)), offset)
+}
+
+// newRacingWebFS returns an FS whose root cell can make a competing write before a CAS.
+func newRacingWebFS(t testing.TB, policy ConflictPolicy) (*FS, *racingCell) {
+	wfs, err := New(VolumeSpec{
+		Cell:  CellSpec{Memory: &struct{}{}},
+		Store: StoreSpec{Memory: &struct{}{}},
+	}, WithPosixFS(posixfs.NewOSFS()), WithConflictPolicy(policy))
+	require.NoError(t, err)
+	cell := &racingCell{Cell: wfs.root.vol.Cell}
+	wfs.root.vol.Cell = cell
+	return wfs, cell
+}
+
+// racingCell calls race, once, before the next CAS.
+type racingCell struct {
+	cells.Cell
+	race func()
+}
+
+func (c *racingCell) CAS(ctx context.Context, actual, prev, next []byte) (bool, int, error) {
+	if race := c.race; race != nil {
+		c.race = nil
+		race()
+	}
+	return c.Cell.CAS(ctx, actual, prev, next)
+}
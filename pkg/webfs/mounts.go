@@ -0,0 +1,93 @@
+package webfs
+
+import (
+	"sync"
+)
+
+// mountPoint is the location of a volume config within another volume.
+type mountPoint struct {
+	parent [32]byte
+	path   string
+}
+
+// mountTable holds the volumeMounts in use by an FS, keyed by the fingerprint of their spec.
+// Reusing mounts keeps the cells, stores and caches for each volume warm across operations.
+type mountTable struct {
+	mu     sync.Mutex
+	root   [32]byte
+	mounts map[[32]byte]*volumeMount
+	// points maps each mount point to the fingerprint of the volume mounted there.
+	points map[mountPoint][32]byte
+}
+
+func newMountTable(root *volumeMount) *mountTable {
+	return &mountTable{
+		root:   root.fingerprint,
+		mounts: map[[32]byte]*volumeMount{root.fingerprint: root},
+		points: map[mountPoint][32]byte{},
+	}
+}
+
+// getOrCreate returns the mount for the volume with fingerprint fp at mp, calling create if it is not in the table.
+// If a different volume was previously mounted at mp, it is evicted if it is no longer mounted anywhere else.
+func (mt *mountTable) getOrCreate(mp mountPoint, fp [32]byte, create func() (*volumeMount, error)) (*volumeMount, error) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	if prev, exists := mt.points[mp]; exists && prev != fp {
+		delete(mt.points, mp)
+		mt.maybeEvict(prev)
+	}
+	vm, exists := mt.mounts[fp]
+	if !exists {
+		var err error
+		if vm, err = create(); err != nil {
+			return nil, err
+		}
+		mt.mounts[fp] = vm
+	}
+	mt.points[mp] = fp
+	return vm, nil
+}
+
+// get returns the mount for the volume with fingerprint fp, or nil if it is not in the table.
+func (mt *mountTable) get(fp [32]byte) *volumeMount {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return mt.mounts[fp]
+}
+
+// unmount is called when there is no longer a config at mp.
+func (mt *mountTable) unmount(mp mountPoint) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	if prev, exists := mt.points[mp]; exists {
+		delete(mt.points, mp)
+		mt.maybeEvict(prev)
+	}
+}
+
+// maybeEvict removes the volume with fingerprint fp, if it is not mounted anywhere.
+// Any volumes mounted only within it are also evicted.
+func (mt *mountTable) maybeEvict(fp [32]byte) {
+	if fp == mt.root {
+		return
+	}
+	for _, fp2 := range mt.points {
+		if fp2 == fp {
+			return
+		}
+	}
+	delete(mt.mounts, fp)
+	for mp, fp2 := range mt.points {
+		if mp.parent == fp {
+			delete(mt.points, mp)
+			mt.maybeEvict(fp2)
+		}
+	}
+}
+
+func (mt *mountTable) len() int {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return len(mt.mounts)
+}
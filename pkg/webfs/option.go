@@ -18,6 +18,7 @@ type fsConfig struct {
 	dialer            TCPDialer
 	blobcacheEndpoint string
 	ipfs              *ipfsapi.Shell
+	conflicts         ConflictPolicy
 }
 
 func defaultConfig() fsConfig {
@@ -66,3 +67,11 @@ func WithIPFS(shell *ipfsapi.Shell) Option {
 		c.ipfs = shell
 	}
 }
+
+// WithConflictPolicy sets how changes to the same path by concurrent writers are resolved.
+// The default is LastWriterWins.
+func WithConflictPolicy(p ConflictPolicy) Option {
+	return func(c *fsConfig) {
+		c.conflicts = p
+	}
+}
@@ -0,0 +1,100 @@
+package webfs
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/brendoncarroll/go-state/cells"
+)
+
+// ReplicateResult describes the blobs transferred by Replicate.
+type ReplicateResult struct {
+	// Copied is the number of blobs which were copied to the destination store.
+	Copied int
+	// CopiedBytes is the total size of the copied blobs.
+	CopiedBytes int64
+}
+
+// Replicate makes the volume described by dst contain the current root of the volume described by src.
+// All the blobs reachable from the root, which are not already in dst's store, are copied from src's store.
+// Then dst's cell is swapped from what it contained before the copy to the root, replacing it.
+// If something else writes to dst in the meantime, the swap fails and nothing is replaced.
+// History and nested volumes are not replicated.
+//
+// Blobs are copied from the bottom of the tree up, and a blob which is already in dst's store is assumed
+// to have everything below it there too, so a Replicate which was interrupted can be resumed by running it again.
+func (fs *FS) Replicate(ctx context.Context, src, dst VolumeSpec) (*ReplicateResult, error) {
+	if src.Fingerprint() == dst.Fingerprint() {
+		return nil, errors.New("cannot replicate a volume to itself")
+	}
+	if dst.Cell.Literal != nil {
+		return nil, errors.New("cannot replicate to a read-only volume")
+	}
+	srcVol, err := fs.volumeForSpec(src)
+	if err != nil {
+		return nil, err
+	}
+	dstVol, err := fs.volumeForSpec(dst)
+	if err != nil {
+		return nil, err
+	}
+	return replicate(ctx, srcVol.vol, dstVol.vol)
+}
+
+func replicate(ctx context.Context, src, dst Volume) (*ReplicateResult, error) {
+	if src.Store.Hash(nil) != dst.Store.Hash(nil) {
+		// the root refers to blobs by their hashes in src's store, so they must be the same in dst's.
+		return nil, errors.New("cannot replicate between stores which use different hash functions")
+	}
+	prev, err := cells.GetBytes(ctx, dst.Cell)
+	if err != nil {
+		return nil, err
+	}
+	rootData, err := cells.GetBytes(ctx, src.Cell)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseRoot(rootData)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, errors.New("cannot replicate an empty volume")
+	}
+	cs := &countingStore{Store: dst.Store}
+	if err := syncRoot(ctx, cs, src.Store, *root); err != nil {
+		return nil, err
+	}
+	actual := make([]byte, dst.Cell.MaxSize())
+	swapped, _, err := dst.Cell.CAS(ctx, actual, prev, rootData)
+	if err != nil {
+		return nil, err
+	}
+	if !swapped {
+		return nil, errors.New("destination volume was changed during replication, it has not been replaced")
+	}
+	return &ReplicateResult{Copied: cs.count, CopiedBytes: cs.bytes}, nil
+}
+
+// countingStore counts the blobs posted to it.
+type countingStore struct {
+	cadata.Store
+
+	mu    sync.Mutex
+	count int
+	bytes int64
+}
+
+func (s *countingStore) Post(ctx context.Context, data []byte) (cadata.ID, error) {
+	id, err := s.Store.Post(ctx, data)
+	if err != nil {
+		return id, err
+	}
+	s.mu.Lock()
+	s.count++
+	s.bytes += int64(len(data))
+	s.mu.Unlock()
+	return id, nil
+}
@@ -0,0 +1,122 @@
+package webfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicate(t *testing.T) {
+	ctx := context.Background()
+	srcSpec, dstSpec := newTestVolumeSpec(t), newTestVolumeSpec(t)
+	wfs, err := New(srcSpec, WithPosixFS(posixfs.NewOSFS()))
+	require.NoError(t, err)
+	require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader(strings.Repeat("a", 1<<20))))
+	require.NoError(t, wfs.PutFile(ctx, "dir/b.txt", strings.NewReader("b")))
+
+	res, err := wfs.Replicate(ctx, srcSpec, dstSpec)
+	require.NoError(t, err)
+	require.Greater(t, res.Copied, 0)
+	require.Greater(t, res.CopiedBytes, int64(1<<20))
+	dstFS, err := New(dstSpec, WithPosixFS(posixfs.NewOSFS()))
+	require.NoError(t, err)
+	require.Equal(t, "b", catString(t, dstFS, "dir/b.txt"))
+	problems, err := dstFS.Check(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, problems)
+
+	// blobs already in the destination are not copied again.
+	res, err = wfs.Replicate(ctx, srcSpec, dstSpec)
+	require.NoError(t, err)
+	require.Equal(t, 0, res.Copied)
+
+	require.NoError(t, wfs.PutFile(ctx, "c.txt", strings.NewReader("c")))
+	_, err = wfs.Replicate(ctx, srcSpec, dstSpec)
+	require.NoError(t, err)
+	require.Equal(t, "c", catString(t, dstFS, "c.txt"))
+}
+
+func TestReplicateResume(t *testing.T) {
+	ctx := context.Background()
+	srcSpec, dstSpec := newTestVolumeSpec(t), newTestVolumeSpec(t)
+	wfs, err := New(srcSpec, WithPosixFS(posixfs.NewOSFS()))
+	require.NoError(t, err)
+	for _, p := range []string{"a.txt", "b/c.txt", "b/d.txt", "e.txt"} {
+		require.NoError(t, wfs.PutFile(ctx, p, strings.NewReader(strings.Repeat(p, 1<<16))))
+	}
+
+	// interrupt a replication part way through.
+	src, err := wfs.volumeForSpec(srcSpec)
+	require.NoError(t, err)
+	dst, err := wfs.volumeForSpec(dstSpec)
+	require.NoError(t, err)
+	root, err := readRoot(ctx, src.vol.Cell)
+	require.NoError(t, err)
+	fs := &failingStore{Store: dst.vol.Store, remaining: 2}
+	require.Error(t, syncRoot(ctx, fs, src.vol.Store, *root))
+
+	_, err = wfs.Replicate(ctx, srcSpec, dstSpec)
+	require.NoError(t, err)
+	dstFS, err := New(dstSpec, WithPosixFS(posixfs.NewOSFS()))
+	require.NoError(t, err)
+	problems, err := dstFS.Check(ctx, "")
+	require.NoError(t, err)
+	require.Empty(t, problems)
+	require.Equal(t, strings.Repeat("b/d.txt", 1<<16), catString(t, dstFS, "b/d.txt"))
+}
+
+func TestReplicateConflict(t *testing.T) {
+	ctx := context.Background()
+	srcSpec, dstSpec := newTestVolumeSpec(t), newTestVolumeSpec(t)
+	wfs, err := New(srcSpec, WithPosixFS(posixfs.NewOSFS()))
+	require.NoError(t, err)
+	require.NoError(t, wfs.PutFile(ctx, "a.txt", strings.NewReader("a")))
+	dstFS, err := New(dstSpec, WithPosixFS(posixfs.NewOSFS()))
+	require.NoError(t, err)
+	src, err := wfs.volumeForSpec(srcSpec)
+	require.NoError(t, err)
+	dst, err := wfs.volumeForSpec(dstSpec)
+	require.NoError(t, err)
+
+	// a write to dst while the blobs are being copied is not overwritten.
+	dstVol := dst.vol
+	dstVol.Cell = &racingCell{Cell: dst.vol.Cell, race: func() {
+		require.NoError(t, dstFS.PutFile(ctx, "b.txt", strings.NewReader("b")))
+	}}
+	_, err = replicate(ctx, src.vol, dstVol)
+	require.ErrorContains(t, err, "was changed")
+	require.Equal(t, "b", catString(t, dstFS, "b.txt"))
+
+	// blobs cannot be shared with a store which uses another hash function.
+	dstVol = dst.vol
+	dstVol.Store = cadata.NewMem(func(x []byte) cadata.ID {
+		return cadata.ID(sha256.Sum256(x))
+	}, MaxBlobSize)
+	_, err = replicate(ctx, src.vol, dstVol)
+	require.ErrorContains(t, err, "hash")
+}
+
+// failingStore fails every Post after the first remaining.
+type failingStore struct {
+	cadata.Store
+
+	mu        sync.Mutex
+	remaining int
+}
+
+func (s *failingStore) Post(ctx context.Context, data []byte) (cadata.ID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.remaining == 0 {
+		return cadata.ID{}, errors.New("interrupted")
+	}
+	s.remaining--
+	return s.Store.Post(ctx, data)
+}
@@ -16,10 +16,14 @@ import (
 	"github.com/brendoncarroll/go-state/cells/cryptocell"
 	"github.com/brendoncarroll/go-state/cells/httpcell"
 	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/gotvc/got/pkg/gotfs"
+	"github.com/gotvc/got/pkg/gotvc"
 	"golang.org/x/crypto/chacha20poly1305"
 
 	"github.com/brendoncarroll/webfs/pkg/cells/filecell"
 	"github.com/brendoncarroll/webfs/pkg/cells/gotcells"
+	"github.com/brendoncarroll/webfs/pkg/cells/literalcell"
+	"github.com/brendoncarroll/webfs/pkg/stores/httpstore"
 	"github.com/brendoncarroll/webfs/pkg/stores/ipfsstore"
 )
 
@@ -28,6 +32,9 @@ type VolumeSpec struct {
 	Cell  CellSpec  `json:"cell"`
 	Store StoreSpec `json:"store"`
 	Salt  []byte    `json:"salt"`
+	// History keeps every version of the volume as a snapshot in its store.
+	// It is shorthand for wrapping Cell in a got_branch cell.
+	History bool `json:"history,omitempty"`
 }
 
 func (vs VolumeSpec) Fingerprint() [32]byte {
@@ -76,8 +83,10 @@ type GotBranchCellSpec struct {
 }
 
 type StoreSpec struct {
-	Memory    *struct{}           `json:"memory,omitempty"`
-	FS        *string             `json:"fs,omitempty"`
+	Memory *struct{} `json:"memory,omitempty"`
+	FS     *string   `json:"fs,omitempty"`
+	// HTTP is a value, not a pointer, as in the first version of the spec, so existing specs keep their fingerprints.
+	// It is set if the URL is not empty.
 	HTTP      HTTPStoreSpec       `json:"http,omitempty"`
 	Blobcache *BlobcacheStoreSpec `json:"blobcache,omitempty"`
 	IPFS      *IPFSStoreSpec      `json:"ipfs,omitempty"`
@@ -88,26 +97,39 @@ type HTTPStoreSpec struct {
 	Headers map[string]string `json:"headers"`
 }
 
+// isZero returns true if no kind of store is set in spec.
+func (spec StoreSpec) isZero() bool {
+	return spec.Memory == nil && spec.FS == nil && spec.HTTP.URL == "" && spec.Blobcache == nil && spec.IPFS == nil
+}
+
 type BlobcacheStoreSpec struct{}
 
 type IPFSStoreSpec struct{}
 
 func (fs *FS) makeVolume(spec VolumeSpec) (*Volume, error) {
-	cell, err := fs.makeCell(spec.Cell)
+	store, err := fs.makeStore(spec.Store)
 	if err != nil {
 		return nil, err
 	}
-	store, err := fs.makeStore(spec.Store)
+	cell, err := fs.makeCell(spec.Cell, store)
 	if err != nil {
 		return nil, err
 	}
+	if spec.History {
+		if spec.Cell.Literal != nil {
+			return nil, errors.New("literal volumes cannot keep history")
+		}
+		vcop := gotvc.NewOperator()
+		cell = gotcells.NewBranch(cell, &vcop, store)
+	}
 	return &Volume{
 		Cell:  cell,
 		Store: store,
 	}, nil
 }
 
-func (fs *FS) makeCell(spec CellSpec) (cells.Cell, error) {
+// makeCell creates the cell described by spec, for a volume using volStore.
+func (fs *FS) makeCell(spec CellSpec, volStore cadata.Store) (cells.Cell, error) {
 	switch {
 	case spec.Memory != nil:
 		return cells.NewMem(1 << 16), nil
@@ -119,10 +141,15 @@ func (fs *FS) makeCell(spec CellSpec) (cells.Cell, error) {
 			Headers: spec.HTTP.Headers,
 		}), nil
 	case spec.Literal != nil:
-		panic("not implemented")
+		// the literal is the JSON encoded root of the volume, check it now instead of on every read.
+		var root gotfs.Root
+		if err := json.Unmarshal(spec.Literal, &root); err != nil {
+			return nil, fmt.Errorf("invalid literal cell: %w", err)
+		}
+		return literalcell.New(spec.Literal), nil
 
 	case spec.AEAD != nil:
-		inner, err := fs.makeCell(spec.AEAD.Inner)
+		inner, err := fs.makeCell(spec.AEAD.Inner, volStore)
 		if err != nil {
 			return nil, err
 		}
@@ -139,15 +166,19 @@ func (fs *FS) makeCell(spec CellSpec) (cells.Cell, error) {
 		}
 		return cryptocell.NewAEAD(inner, aead), nil
 	case spec.GotBranch != nil:
-		inner, err := fs.makeCell(spec.GotBranch.Inner)
+		inner, err := fs.makeCell(spec.GotBranch.Inner, volStore)
 		if err != nil {
 			return nil, err
 		}
-		// vcstore, err := fs.makeStore(spec.GotBranch.VCStore)
-		// if err != nil {
-		// 	return nil, err
-		// }
-		return gotcells.NewBranch(inner, nil, nil), nil
+		// snapshots are kept with the data, unless another store is specified.
+		vcStore := volStore
+		if !spec.GotBranch.VCStore.isZero() {
+			if vcStore, err = fs.makeStore(spec.GotBranch.VCStore); err != nil {
+				return nil, err
+			}
+		}
+		vcop := gotvc.NewOperator()
+		return gotcells.NewBranch(inner, &vcop, vcStore), nil
 	default:
 		return nil, errors.New("empty cell spec")
 	}
@@ -163,6 +194,8 @@ func (fs *FS) makeStore(spec StoreSpec) (cadata.Store, error) {
 		}
 		pfs := posixfs.NewDirFS(*spec.FS)
 		return fsstore.New(pfs, Hash, MaxBlobSize), nil
+	case spec.HTTP.URL != "":
+		return httpstore.New(spec.HTTP.URL, Hash, MaxBlobSize, spec.HTTP.Headers), nil
 	case spec.Blobcache != nil:
 		c, err := bcclient.NewClient(fs.config.blobcacheEndpoint)
 		if err != nil {
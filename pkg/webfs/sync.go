@@ -0,0 +1,367 @@
+package webfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// SyncOp is a kind of change made by a sync.
+type SyncOp string
+
+const (
+	SyncMkdir  = SyncOp("mkdir")
+	SyncPut    = SyncOp("put")
+	SyncRemove = SyncOp("remove")
+)
+
+// SyncAction is a change made by a sync, or which would be made by a dry run.
+type SyncAction struct {
+	Op SyncOp
+	// Path is the path in the destination.
+	Path string
+}
+
+// SyncOption configures SyncFromLocal and SyncToLocal.
+type SyncOption func(c *syncConfig)
+
+type syncConfig struct {
+	delete   bool
+	dryRun   bool
+	checksum bool
+}
+
+// SyncDelete removes anything from the destination which is not in the source.
+func SyncDelete() SyncOption {
+	return func(c *syncConfig) {
+		c.delete = true
+	}
+}
+
+// SyncDryRun finds the changes which would be made, without making them.
+func SyncDryRun() SyncOption {
+	return func(c *syncConfig) {
+		c.dryRun = true
+	}
+}
+
+// SyncChecksum compares the contents of files, instead of their sizes and modification times.
+func SyncChecksum() SyncOption {
+	return func(c *syncConfig) {
+		c.checksum = true
+	}
+}
+
+// errDryRun aborts the transaction used by a dry run.
+var errDryRun = errors.New("dry run")
+
+// SyncFromLocal makes dst the same as the directory or file at local, in the local filesystem.
+// Only files which differ are uploaded, and all the changes are committed in one transaction.
+// Entries which are neither regular files nor directories are skipped.
+func (fs *FS) SyncFromLocal(ctx context.Context, dst, local string, opts ...SyncOption) ([]SyncAction, error) {
+	s := newSyncer(fs, opts)
+	err := fs.Txn(ctx, func(tx *Tx) error {
+		s.tx = tx
+		if err := s.upload(cleanPath(dst), local); err != nil {
+			return err
+		}
+		if s.config.dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return nil, err
+	}
+	return s.actions, nil
+}
+
+// SyncToLocal makes the directory or file at local, in the local filesystem, the same as src.
+// Only files which differ are downloaded. Each file is replaced atomically, but the sync as a whole is not.
+// Volume configs are copied as files, the volumes they describe are not, and are left alone in local.
+func (fs *FS) SyncToLocal(ctx context.Context, src, local string, opts ...SyncOption) ([]SyncAction, error) {
+	s := newSyncer(fs, opts)
+	s.ctx = ctx
+	if err := s.download(cleanPath(src), local); err != nil {
+		return nil, err
+	}
+	return s.actions, nil
+}
+
+type syncer struct {
+	fs     *FS
+	config syncConfig
+	// ctx is used for downloads, uploads use the transaction's context.
+	ctx context.Context
+	tx  *Tx
+
+	actions []SyncAction
+}
+
+func newSyncer(fs *FS, opts []SyncOption) *syncer {
+	var config syncConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return &syncer{fs: fs, config: config}
+}
+
+// do records an action, and performs it unless this is a dry run.
+func (s *syncer) do(op SyncOp, p string, fn func() error) error {
+	s.actions = append(s.actions, SyncAction{Op: op, Path: p})
+	if s.config.dryRun {
+		return nil
+	}
+	return fn()
+}
+
+func (s *syncer) upload(dst, local string) error {
+	linfo, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	winfo, err := s.tx.Stat(dst)
+	if err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return err
+	}
+	exists := err == nil
+	if !linfo.IsDir() {
+		if exists && winfo.Mode().IsRegular() && winfo.Size() == linfo.Size() {
+			if same, err := s.sameUpload(dst, local, winfo, linfo); err != nil || same {
+				return err
+			}
+		}
+		return s.do(SyncPut, dst, func() error {
+			f, err := os.Open(local)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if err := s.tx.PutFile(dst, f); err != nil {
+				return err
+			}
+			return s.tx.Chtimes(dst, linfo.ModTime())
+		})
+	}
+
+	if exists && !winfo.IsDir() {
+		if err := s.do(SyncRemove, dst, func() error { return s.tx.Remove(dst) }); err != nil {
+			return err
+		}
+		exists = false
+	}
+	if !exists {
+		if err := s.do(SyncMkdir, dst, func() error { return s.tx.Mkdir(dst) }); err != nil {
+			return err
+		}
+	}
+	ents, err := os.ReadDir(local)
+	if err != nil {
+		return err
+	}
+	names := map[string]struct{}{}
+	for _, ent := range ents {
+		if !ent.IsDir() && !ent.Type().IsRegular() {
+			continue
+		}
+		names[ent.Name()] = struct{}{}
+		if err := s.upload(path.Join(dst, ent.Name()), filepath.Join(local, ent.Name())); err != nil {
+			return err
+		}
+	}
+	if s.config.delete && exists {
+		wents, err := s.tx.ReadDir(dst)
+		if err != nil {
+			return err
+		}
+		for _, went := range wents {
+			if _, keep := names[went.Name()]; keep {
+				continue
+			}
+			p := path.Join(dst, went.Name())
+			if err := s.do(SyncRemove, p, func() error { return s.tx.Remove(p) }); err != nil {
+				return err
+			}
+		}
+	}
+	if s.config.dryRun {
+		return nil
+	}
+	// directories are touched by changing their contents, so this must happen last.
+	if winfo, err = s.tx.Stat(dst); err != nil {
+		return err
+	}
+	if !winfo.ModTime().Equal(linfo.ModTime()) {
+		return s.tx.Chtimes(dst, linfo.ModTime())
+	}
+	return nil
+}
+
+// sameUpload returns true if the file at dst does not need to be replaced by the file at local.
+// The files are already known to have the same size.
+func (s *syncer) sameUpload(dst, local string, winfo, linfo iofs.FileInfo) (bool, error) {
+	if !s.config.checksum {
+		return winfo.ModTime().Equal(linfo.ModTime()), nil
+	}
+	lsum, err := hashLocalFile(local)
+	if err != nil {
+		return false, err
+	}
+	wsum, err := s.tx.hashContent(dst)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(lsum, wsum), nil
+}
+
+func (s *syncer) download(src, local string) error {
+	ctx := s.ctx
+	winfo, err := s.fs.Stat(ctx, src)
+	if err != nil {
+		return err
+	}
+	linfo, err := os.Lstat(local)
+	if err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return err
+	}
+	exists := err == nil
+	if !winfo.IsDir() {
+		if exists && linfo.Mode().IsRegular() && linfo.Size() == winfo.Size() {
+			if same, err := s.sameDownload(src, local, winfo, linfo); err != nil || same {
+				return err
+			}
+		}
+		return s.do(SyncPut, local, func() error {
+			if exists && linfo.IsDir() {
+				if err := os.RemoveAll(local); err != nil {
+					return err
+				}
+			}
+			return s.downloadFile(src, local, winfo)
+		})
+	}
+
+	if exists && !linfo.IsDir() {
+		if err := s.do(SyncRemove, local, func() error { return os.Remove(local) }); err != nil {
+			return err
+		}
+		exists = false
+	}
+	if !exists {
+		if err := s.do(SyncMkdir, local, func() error { return os.Mkdir(local, 0o755) }); err != nil {
+			return err
+		}
+	}
+	names := map[string]struct{}{}
+	var wents []iofs.DirEntry
+	if err := s.fs.Ls(ctx, src, func(ent iofs.DirEntry) error {
+		wents = append(wents, ent)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, went := range wents {
+		names[went.Name()] = struct{}{}
+		if isMount(went) {
+			continue
+		}
+		if err := s.download(path.Join(src, went.Name()), filepath.Join(local, went.Name())); err != nil {
+			return err
+		}
+	}
+	if s.config.delete && exists {
+		lents, err := os.ReadDir(local)
+		if err != nil {
+			return err
+		}
+		for _, lent := range lents {
+			if _, keep := names[lent.Name()]; keep {
+				continue
+			}
+			p := filepath.Join(local, lent.Name())
+			if err := s.do(SyncRemove, p, func() error { return os.RemoveAll(p) }); err != nil {
+				return err
+			}
+		}
+	}
+	if s.config.dryRun {
+		return nil
+	}
+	return os.Chtimes(local, winfo.ModTime(), winfo.ModTime())
+}
+
+// isMount returns true if ent is a volume listed next to its config.
+func isMount(ent iofs.DirEntry) bool {
+	de, ok := ent.(*dirEntry)
+	return ok && de.mount
+}
+
+// sameDownload returns true if the file at local does not need to be replaced by the file at src.
+// The files are already known to have the same size.
+func (s *syncer) sameDownload(src, local string, winfo, linfo iofs.FileInfo) (bool, error) {
+	if !s.config.checksum {
+		return winfo.ModTime().Equal(linfo.ModTime()), nil
+	}
+	lsum, err := hashLocalFile(local)
+	if err != nil {
+		return false, err
+	}
+	f, err := s.fs.Open(s.ctx, src)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	h, _ := blake2b.New256(nil)
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return bytes.Equal(lsum, h.Sum(nil)), nil
+}
+
+// downloadFile writes the file at src to local, replacing it atomically.
+func (s *syncer) downloadFile(src, local string, winfo iofs.FileInfo) error {
+	r, err := s.fs.Open(s.ctx, src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.CreateTemp(filepath.Dir(local), ".webfs-sync-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), winfo.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chtimes(f.Name(), winfo.ModTime(), winfo.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), local)
+}
+
+func hashLocalFile(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h, _ := blake2b.New256(nil)
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,171 @@
+package webfs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncFromLocal(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	dir := t.TempDir()
+	writeLocal(t, dir, "a/x.txt", "x")
+	writeLocal(t, dir, "b.txt", "b")
+
+	actions, err := wfs.SyncFromLocal(ctx, "dst", dir)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []SyncAction{
+		{Op: SyncMkdir, Path: "dst"},
+		{Op: SyncMkdir, Path: "dst/a"},
+		{Op: SyncPut, Path: "dst/a/x.txt"},
+		{Op: SyncPut, Path: "dst/b.txt"},
+	}, actions)
+	require.Equal(t, "x", catString(t, wfs, "dst/a/x.txt"))
+	requireSameModTime(t, wfs, "dst/a", filepath.Join(dir, "a"))
+	requireSameModTime(t, wfs, "dst/b.txt", filepath.Join(dir, "b.txt"))
+
+	// nothing has changed.
+	cell := &casCountingCell{Cell: wfs.root.vol.Cell}
+	wfs.root.vol.Cell = cell
+	actions, err = wfs.SyncFromLocal(ctx, "dst", dir)
+	require.NoError(t, err)
+	require.Empty(t, actions)
+	require.Equal(t, 0, cell.cas)
+
+	writeLocal(t, dir, "b.txt", "b2")
+	writeLocal(t, dir, "c.txt", "c")
+	require.NoError(t, os.Remove(filepath.Join(dir, "a/x.txt")))
+	require.NoError(t, wfs.PutFile(ctx, "dst/extra.txt", strings.NewReader("extra")))
+	expected := []SyncAction{
+		{Op: SyncPut, Path: "dst/b.txt"},
+		{Op: SyncPut, Path: "dst/c.txt"},
+		{Op: SyncRemove, Path: "dst/a/x.txt"},
+		{Op: SyncRemove, Path: "dst/extra.txt"},
+	}
+	cell.cas = 0
+	actions, err = wfs.SyncFromLocal(ctx, "dst", dir, SyncDelete(), SyncDryRun())
+	require.NoError(t, err)
+	require.ElementsMatch(t, expected, actions)
+	require.Equal(t, 0, cell.cas)
+	require.Equal(t, "b", catString(t, wfs, "dst/b.txt"))
+
+	actions, err = wfs.SyncFromLocal(ctx, "dst", dir, SyncDelete())
+	require.NoError(t, err)
+	require.ElementsMatch(t, expected, actions)
+	require.Equal(t, 1, cell.cas)
+	require.Equal(t, "b2", catString(t, wfs, "dst/b.txt"))
+	require.Equal(t, "c", catString(t, wfs, "dst/c.txt"))
+	for _, p := range []string{"dst/a/x.txt", "dst/extra.txt"} {
+		_, err := wfs.Stat(ctx, p)
+		require.True(t, posixfs.IsErrNotExist(err))
+	}
+}
+
+func TestSyncChecksum(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	dir := t.TempDir()
+	writeLocal(t, dir, "a.txt", "aaa")
+	_, err := wfs.SyncFromLocal(ctx, "", dir)
+	require.NoError(t, err)
+
+	// same size and modification time, different contents.
+	finfo, err := os.Stat(filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	writeLocal(t, dir, "a.txt", "bbb")
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "a.txt"), finfo.ModTime(), finfo.ModTime()))
+
+	actions, err := wfs.SyncFromLocal(ctx, "", dir)
+	require.NoError(t, err)
+	require.Empty(t, actions)
+	actions, err = wfs.SyncFromLocal(ctx, "", dir, SyncChecksum())
+	require.NoError(t, err)
+	require.Equal(t, []SyncAction{{Op: SyncPut, Path: "a.txt"}}, actions)
+	require.Equal(t, "bbb", catString(t, wfs, "a.txt"))
+}
+
+func TestSyncToLocal(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	require.NoError(t, wfs.PutFile(ctx, "src/a/x.txt", strings.NewReader("x")))
+	require.NoError(t, wfs.PutFile(ctx, "src/b.txt", strings.NewReader("b")))
+	dir := filepath.Join(t.TempDir(), "dst")
+
+	actions, err := wfs.SyncToLocal(ctx, "src", dir)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []SyncAction{
+		{Op: SyncMkdir, Path: dir},
+		{Op: SyncMkdir, Path: filepath.Join(dir, "a")},
+		{Op: SyncPut, Path: filepath.Join(dir, "a/x.txt")},
+		{Op: SyncPut, Path: filepath.Join(dir, "b.txt")},
+	}, actions)
+	require.Equal(t, "x", readLocal(t, dir, "a/x.txt"))
+	requireSameModTime(t, wfs, "src/b.txt", filepath.Join(dir, "b.txt"))
+	requireSameModTime(t, wfs, "src", dir)
+
+	actions, err = wfs.SyncToLocal(ctx, "src", dir)
+	require.NoError(t, err)
+	require.Empty(t, actions)
+
+	writeLocal(t, dir, "extra.txt", "extra")
+	require.NoError(t, wfs.PutFile(ctx, "src/b.txt", strings.NewReader("b2")))
+	actions, err = wfs.SyncToLocal(ctx, "src", dir, SyncDelete())
+	require.NoError(t, err)
+	require.ElementsMatch(t, []SyncAction{
+		{Op: SyncPut, Path: filepath.Join(dir, "b.txt")},
+		{Op: SyncRemove, Path: filepath.Join(dir, "extra.txt")},
+	}, actions)
+	require.Equal(t, "b2", readLocal(t, dir, "b.txt"))
+	_, err = os.Stat(filepath.Join(dir, "extra.txt"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestSyncToLocalVolume(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	putVolumeSpec(t, wfs, "src/vol.webfs", newTestVolumeSpec(t))
+	require.NoError(t, wfs.PutFile(ctx, "src/vol/c.txt", strings.NewReader("c")))
+	dir := filepath.Join(t.TempDir(), "dst")
+
+	actions, err := wfs.SyncToLocal(ctx, "src", dir)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []SyncAction{
+		{Op: SyncMkdir, Path: dir},
+		{Op: SyncPut, Path: filepath.Join(dir, "vol.webfs")},
+	}, actions)
+	_, err = os.Stat(filepath.Join(dir, "vol"))
+	require.True(t, os.IsNotExist(err))
+
+	// the volume is left alone in local.
+	writeLocal(t, dir, "vol/c.txt", "local")
+	actions, err = wfs.SyncToLocal(ctx, "src", dir, SyncDelete())
+	require.NoError(t, err)
+	require.Empty(t, actions)
+	require.Equal(t, "local", readLocal(t, dir, "vol/c.txt"))
+}
+
+func writeLocal(t testing.TB, dir, p, data string) {
+	p = filepath.Join(dir, filepath.FromSlash(p))
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+	require.NoError(t, os.WriteFile(p, []byte(data), 0o644))
+}
+
+func readLocal(t testing.TB, dir, p string) string {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func requireSameModTime(t testing.TB, wfs *FS, p, local string) {
+	winfo, err := wfs.Stat(context.Background(), p)
+	require.NoError(t, err)
+	linfo, err := os.Stat(local)
+	require.NoError(t, err)
+	require.True(t, winfo.ModTime().Equal(linfo.ModTime()), "%v != %v", winfo.ModTime(), linfo.ModTime())
+}
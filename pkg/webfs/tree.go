@@ -0,0 +1,131 @@
+package webfs
+
+import (
+	"context"
+	"fmt"
+	iofs "io/fs"
+	"strings"
+	"time"
+
+	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/gotvc/got/pkg/gotfs"
+)
+
+// LabelModTime is the gotfs label used to store the modification time of a file or directory.
+const LabelModTime = "webfs.mtime"
+
+func getModTime(info *gotfs.Info) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, info.Labels[LabelModTime])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func setModTimeLabel(info *gotfs.Info, mtime time.Time) {
+	if info.Labels == nil {
+		info.Labels = map[string]string{}
+	}
+	info.Labels[LabelModTime] = mtime.UTC().Format(time.RFC3339Nano)
+}
+
+// setModTime records mtime as the modification time of the entry at p.
+func (v *volumeMount) setModTime(ctx context.Context, root gotfs.Root, p string, mtime time.Time) (*gotfs.Root, error) {
+	ms := v.vol.Store
+	info, err := v.gotfs.GetInfo(ctx, ms, root, p)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	setModTimeLabel(info, mtime)
+	return v.gotfs.PutInfo(ctx, ms, root, p, info)
+}
+
+// setMode sets the permission bits of the entry at p to those of mode, the type of the entry is unchanged.
+func (v *volumeMount) setMode(ctx context.Context, root gotfs.Root, p string, mode iofs.FileMode) (*gotfs.Root, error) {
+	ms := v.vol.Store
+	info, err := v.gotfs.GetInfo(ctx, ms, root, p)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	info.Mode = uint32(iofs.FileMode(info.Mode)&^iofs.ModePerm | mode&iofs.ModePerm)
+	return v.gotfs.PutInfo(ctx, ms, root, p, info)
+}
+
+// touchParent updates the modification time of the directory containing p, if it exists.
+// It should be called whenever an entry is added to or removed from a directory.
+func (v *volumeMount) touchParent(ctx context.Context, root gotfs.Root, p string, mtime time.Time) (*gotfs.Root, error) {
+	p = cleanPath(p)
+	if p == "" {
+		return &root, nil
+	}
+	y, err := v.setModTime(ctx, root, parentOf(p), mtime)
+	if posixfs.IsErrNotExist(err) {
+		return &root, nil
+	}
+	return y, err
+}
+
+// mkdirAll creates the directory p and any of its missing ancestors, with mtime as their modification time.
+func (v *volumeMount) mkdirAll(ctx context.Context, root gotfs.Root, p string, mtime time.Time) (*gotfs.Root, error) {
+	ms := v.vol.Store
+	p = cleanPath(p)
+	var parts []string
+	if p != "" {
+		parts = strings.Split(p, "/")
+	}
+	x := &root
+	for i := 0; i <= len(parts); i++ {
+		p2 := strings.Join(parts[:i], "/")
+		info, err := v.gotfs.GetInfo(ctx, ms, *x, p2)
+		if err == nil {
+			if !iofs.FileMode(info.Mode).IsDir() {
+				return nil, fmt.Errorf("%q is not a directory", p2)
+			}
+			continue
+		} else if !posixfs.IsErrNotExist(err) {
+			return nil, err
+		}
+		info = &gotfs.Info{Mode: uint32(0o755 | iofs.ModeDir)}
+		setModTimeLabel(info, mtime)
+		if x, err = v.gotfs.PutInfo(ctx, ms, *x, p2, info); err != nil {
+			return nil, err
+		}
+		if x, err = v.touchParent(ctx, *x, p2, mtime); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// place puts branch at p, replacing anything already there.
+func (v *volumeMount) place(ctx context.Context, root gotfs.Root, p string, branch gotfs.Root, mtime time.Time) (*gotfs.Root, error) {
+	ms, ds := v.vol.Store, v.vol.Store
+	x, err := v.gotfs.RemoveAll(ctx, ms, root, p)
+	if err != nil {
+		return nil, err
+	}
+	if p != "" {
+		if x, err = v.mkdirAll(ctx, *x, parentOf(p), mtime); err != nil {
+			return nil, err
+		}
+	}
+	if x, err = v.gotfs.Graft(ctx, ms, ds, *x, p, branch); err != nil {
+		return nil, err
+	}
+	return v.touchParent(ctx, *x, p, mtime)
+}
+
+// remove deletes everything at p.
+func (v *volumeMount) remove(ctx context.Context, root gotfs.Root, p string, mtime time.Time) (*gotfs.Root, error) {
+	ms := v.vol.Store
+	if _, err := v.gotfs.GetInfo(ctx, ms, root, p); posixfs.IsErrNotExist(err) {
+		return &root, nil
+	} else if err != nil {
+		return nil, err
+	}
+	x, err := v.gotfs.RemoveAll(ctx, ms, root, p)
+	if err != nil {
+		return nil, err
+	}
+	return v.touchParent(ctx, *x, p, mtime)
+}
@@ -0,0 +1,168 @@
+package webfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"time"
+
+	"github.com/brendoncarroll/go-state/cells"
+	"github.com/gotvc/got/pkg/gotfs"
+)
+
+// Txn calls fn with a transaction, and commits all the changes made through it with a single CAS.
+// If fn returns an error nothing is committed.
+// A transaction can only change one volume, which is chosen by the first path it changes.
+// Each path is resolved against the volume configs as they are when it is used, not against the
+// working root, so volumes configured by the transaction cannot be changed in it.
+func (fs *FS) Txn(ctx context.Context, fn func(tx *Tx) error) error {
+	tx := &Tx{ctx: ctx, fs: fs}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if tx.vm == nil || rootsEqual(tx.base, tx.root) {
+		return nil
+	}
+	return tx.vm.commit(ctx, tx.prev, tx.base, tx.root, tx.touched)
+}
+
+// Tx is a set of changes to a volume, which are applied to a working root until they are committed.
+// A Tx must not be used after the function it was passed to returns.
+type Tx struct {
+	ctx context.Context
+	fs  *FS
+
+	vm *volumeMount
+	// prev is the contents of the cell when the transaction started, and base is the root in it.
+	prev []byte
+	base *gotfs.Root
+	// root is the working root.
+	root *gotfs.Root
+	// touched holds the paths changed by the transaction.
+	touched []string
+}
+
+// PutFile creates or replaces the file at p with the contents of r.
+func (tx *Tx) PutFile(p string, r io.Reader) error {
+	return tx.modify(p, func(v *volumeMount, root *gotfs.Root, p string) (*gotfs.Root, error) {
+		return v.putFile(tx.ctx, root, p, r)
+	})
+}
+
+// Mkdir creates the directory at p, and any of its missing parents.
+func (tx *Tx) Mkdir(p string) error {
+	return tx.modify(p, func(v *volumeMount, root *gotfs.Root, p string) (*gotfs.Root, error) {
+		return v.mkdir(tx.ctx, root, p)
+	})
+}
+
+// Remove removes the file or directory at p, and everything in it.
+func (tx *Tx) Remove(p string) error {
+	return tx.modify(p, func(v *volumeMount, root *gotfs.Root, p string) (*gotfs.Root, error) {
+		return v.rm(tx.ctx, root, p)
+	})
+}
+
+// Chtimes sets the modification time of the file or directory at p.
+func (tx *Tx) Chtimes(p string, mtime time.Time) error {
+	return tx.modify(p, func(v *volumeMount, root *gotfs.Root, p string) (*gotfs.Root, error) {
+		return v.chtimes(tx.ctx, root, p, mtime)
+	})
+}
+
+// Chmod sets the permission bits of the file or directory at p.
+func (tx *Tx) Chmod(p string, mode iofs.FileMode) error {
+	return tx.modify(p, func(v *volumeMount, root *gotfs.Root, p string) (*gotfs.Root, error) {
+		return v.chmod(tx.ctx, root, p, mode)
+	})
+}
+
+// Rename moves the file or directory at src to dst, replacing anything at dst.
+// src and dst must be in the same volume.
+func (tx *Tx) Rename(src, dst string) error {
+	dstRes, err := tx.resolve(dst)
+	if err != nil {
+		return err
+	}
+	if err := tx.modify(src, func(v *volumeMount, root *gotfs.Root, src string) (*gotfs.Root, error) {
+		return v.rename(tx.ctx, root, src, dstRes.Path)
+	}); err != nil {
+		return err
+	}
+	tx.touched = append(tx.touched, dstRes.Path)
+	return nil
+}
+
+// Stat returns information about the file or directory at p, including changes made by the transaction.
+func (tx *Tx) Stat(p string) (iofs.FileInfo, error) {
+	res, err := tx.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if tx.root == nil {
+		return nil, iofs.ErrNotExist
+	}
+	return tx.vm.stat(tx.ctx, *tx.root, res.Path)
+}
+
+// ReadDir returns the entries of the directory at p, including changes made by the transaction.
+func (tx *Tx) ReadDir(p string) ([]iofs.DirEntry, error) {
+	res, err := tx.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	return tx.vm.readDir(tx.ctx, tx.root, res.Path, "", 0)
+}
+
+// hashContent returns a hash of the contents of the file at p, including changes made by the transaction.
+func (tx *Tx) hashContent(p string) ([]byte, error) {
+	res, err := tx.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if tx.root == nil {
+		return nil, iofs.ErrNotExist
+	}
+	return tx.vm.hashContent(tx.ctx, *tx.root, res.Path)
+}
+
+// modify applies fn to the working root, p is the path being changed.
+func (tx *Tx) modify(p string, fn func(v *volumeMount, root *gotfs.Root, p string) (*gotfs.Root, error)) error {
+	res, err := tx.resolve(p)
+	if err != nil {
+		return err
+	}
+	if res.VM.readOnly {
+		return ErrReadOnly{Path: res.Path}
+	}
+	root, err := fn(res.VM, tx.root, res.Path)
+	if err != nil {
+		return err
+	}
+	tx.root = root
+	tx.touched = append(tx.touched, res.Path)
+	return nil
+}
+
+// resolve resolves p, and starts the transaction on its volume if this is the first path used.
+func (tx *Tx) resolve(p string) (*resolveRes, error) {
+	res, err := tx.fs.resolve(tx.ctx, tx.fs.root, p)
+	if err != nil {
+		return nil, err
+	}
+	if tx.vm == nil {
+		prev, err := cells.GetBytes(tx.ctx, res.VM.vol.Cell)
+		if err != nil {
+			return nil, err
+		}
+		base, err := parseRoot(prev)
+		if err != nil {
+			return nil, err
+		}
+		tx.vm, tx.prev, tx.base, tx.root = res.VM, prev, base, base
+	} else if !tx.vm.sameVolume(res.VM) {
+		return nil, fmt.Errorf("cannot use %q in transaction: it is in a different volume", p)
+	}
+	return res, nil
+}
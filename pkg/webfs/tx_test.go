@@ -0,0 +1,95 @@
+package webfs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/cells"
+	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxn(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	require.NoError(t, wfs.PutFile(ctx, "old.txt", strings.NewReader("old")))
+	cell := &casCountingCell{Cell: wfs.root.vol.Cell}
+	wfs.root.vol.Cell = cell
+
+	require.NoError(t, wfs.Txn(ctx, func(tx *Tx) error {
+		if err := tx.Mkdir("dir"); err != nil {
+			return err
+		}
+		for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+			if err := tx.PutFile("dir/"+name, strings.NewReader(name)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Rename("dir/c.txt", "c.txt"); err != nil {
+			return err
+		}
+		if _, err := tx.Stat("c.txt"); err != nil {
+			return err
+		}
+		// nothing is visible outside the transaction until it commits.
+		if _, err := wfs.Stat(ctx, "dir"); !posixfs.IsErrNotExist(err) {
+			return errors.New("transaction is not isolated")
+		}
+		return tx.Remove("old.txt")
+	}))
+	require.Equal(t, 1, cell.cas)
+
+	require.Equal(t, "a.txt", catString(t, wfs, "dir/a.txt"))
+	require.Equal(t, "b.txt", catString(t, wfs, "dir/b.txt"))
+	require.Equal(t, "c.txt", catString(t, wfs, "c.txt"))
+	_, err := wfs.Stat(ctx, "old.txt")
+	require.True(t, posixfs.IsErrNotExist(err))
+}
+
+func TestTxnAbort(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	errAbort := errors.New("abort")
+	err := wfs.Txn(ctx, func(tx *Tx) error {
+		if err := tx.PutFile("a.txt", strings.NewReader("a")); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	require.Equal(t, errAbort, err)
+	_, err = wfs.Stat(ctx, "a.txt")
+	require.True(t, posixfs.IsErrNotExist(err))
+}
+
+func TestTxnVolumes(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	putVolumeSpec(t, wfs, "vol.webfs", newTestVolumeSpec(t))
+
+	err := wfs.Txn(ctx, func(tx *Tx) error {
+		if err := tx.PutFile("vol/a.txt", strings.NewReader("a")); err != nil {
+			return err
+		}
+		return tx.PutFile("b.txt", strings.NewReader("b"))
+	})
+	require.Error(t, err)
+	_, err = wfs.Stat(ctx, "vol/a.txt")
+	require.True(t, posixfs.IsErrNotExist(err))
+
+	require.NoError(t, wfs.Txn(ctx, func(tx *Tx) error {
+		return tx.PutFile("vol/a.txt", strings.NewReader("a"))
+	}))
+	require.Equal(t, "a", catString(t, wfs, "vol/a.txt"))
+}
+
+type casCountingCell struct {
+	cells.Cell
+	cas int
+}
+
+func (c *casCountingCell) CAS(ctx context.Context, actual, prev, next []byte) (bool, int, error) {
+	c.cas++
+	return c.Cell.CAS(ctx, actual, prev, next)
+}
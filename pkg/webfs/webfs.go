@@ -1,11 +1,14 @@
 package webfs
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	iofs "io/fs"
+	"os"
 	"path"
 	"strings"
 	"time"
@@ -15,7 +18,9 @@ import (
 	"github.com/brendoncarroll/go-state/posixfs"
 	"github.com/gotvc/got/pkg/gdat"
 	"github.com/gotvc/got/pkg/gotfs"
+	"github.com/gotvc/got/pkg/gotkv"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
@@ -38,7 +43,8 @@ type FS struct {
 	fs     posixfs.FS
 	log    logrus.FieldLogger
 
-	root *volumeMount
+	root   *volumeMount
+	mounts *mountTable
 }
 
 func New(vspec VolumeSpec, opts ...Option) (*FS, error) {
@@ -51,21 +57,52 @@ func New(vspec VolumeSpec, opts ...Option) (*FS, error) {
 		fs:     config.pfs,
 		log:    config.log,
 	}
-	root, err := fs.getVolumeMount(context.Background(), nil, "", &vspec)
+	root, err := fs.newVolumeMount(vspec)
 	if err != nil {
 		return nil, err
 	}
 	fs.root = root
+	fs.mounts = newMountTable(root)
 	return fs, nil
 }
 
+// Fingerprint returns the fingerprint of the spec of the root volume.
+// It identifies the volume, and does not change when the volume's contents do.
+func (fs *FS) Fingerprint() [32]byte {
+	return fs.root.fingerprint
+}
+
+// Open opens the file or directory at p for reading.
+// ctx is retained by the File and used for all of its operations which do not take a context.
 func (fs *FS) Open(ctx context.Context, p string) (*File, error) {
 	res, err := fs.resolve(ctx, fs.root, p)
 	if err != nil {
 		return nil, err
 	}
 	fs.log.Infof("open %q", p)
-	return res.VM.Open(res.Path)
+	f, err := res.VM.Open(ctx, res.Path)
+	if err != nil {
+		return nil, err
+	}
+	f.fs = fs
+	return f, nil
+}
+
+// OpenFile opens the file at p using flags from the os package (os.O_RDWR, os.O_CREATE, etc).
+// perm is used as the mode of the file if it is created.
+// Writes made through the returned File are committed when it is synced or closed.
+func (fs *FS) OpenFile(ctx context.Context, p string, flag int, perm iofs.FileMode) (*File, error) {
+	res, err := fs.resolve(ctx, fs.root, p)
+	if err != nil {
+		return nil, err
+	}
+	fs.log.Infof("open %q flag=%x", p, flag)
+	f, err := res.VM.OpenFile(ctx, res.Path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	f.fs = fs
+	return f, nil
 }
 
 func (fs *FS) PutFile(ctx context.Context, p string, r io.Reader) error {
@@ -84,12 +121,67 @@ func (fs *FS) Mkdir(ctx context.Context, p string) error {
 	return res.VM.Mkdir(ctx, res.Path)
 }
 
+// Stat returns information about the file or directory at p.
+func (fs *FS) Stat(ctx context.Context, p string) (iofs.FileInfo, error) {
+	res, err := fs.resolve(ctx, fs.root, p)
+	if err != nil {
+		return nil, err
+	}
+	return res.VM.Stat(ctx, res.Path)
+}
+
+// Chtimes sets the modification time of the file or directory at p.
+func (fs *FS) Chtimes(ctx context.Context, p string, mtime time.Time) error {
+	res, err := fs.resolve(ctx, fs.root, p)
+	if err != nil {
+		return err
+	}
+	return res.VM.Chtimes(ctx, res.Path, mtime)
+}
+
 func (fs *FS) Remove(ctx context.Context, p string) error {
 	res, err := fs.resolve(ctx, fs.root, p)
 	if err != nil {
 		return err
 	}
-	return res.VM.Rm(ctx, p)
+	return res.VM.Rm(ctx, res.Path)
+}
+
+// Rename moves the file or directory at src to dst, replacing anything at dst.
+// If src and dst are in the same volume the move is a single atomic swap of the volume's cell.
+// Otherwise the data is copied into the volume containing dst, and then removed from src.
+// dst is only replaced once the copy is complete, so a failed move leaves both src and dst as they were.
+func (fs *FS) Rename(ctx context.Context, src, dst string) error {
+	src, dst = cleanPath(src), cleanPath(dst)
+	if src == "" || dst == "" {
+		return errors.New("cannot rename the root")
+	}
+	srcRes, err := fs.resolve(ctx, fs.root, src)
+	if err != nil {
+		return err
+	}
+	if srcRes.Path == "" {
+		// src is a mount point, move the config instead of the volume's contents.
+		return fs.Rename(ctx, src+".webfs", dst+".webfs")
+	}
+	dstRes, err := fs.resolve(ctx, fs.root, dst)
+	if err != nil {
+		return err
+	}
+	if dstRes.Path == "" {
+		return fmt.Errorf("cannot replace volume mounted at %q", dst)
+	}
+	if srcRes.VM.sameVolume(dstRes.VM) {
+		return srcRes.VM.Rename(ctx, srcRes.Path, dstRes.Path)
+	}
+	if srcRes.VM.readOnly {
+		return ErrReadOnly{Path: srcRes.Path}
+	}
+	fs.log.Infof("rename %q -> %q crosses volumes, copying", src, dst)
+	if err := fs.Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	return srcRes.VM.Rm(ctx, srcRes.Path)
 }
 
 func (fs *FS) Cat(ctx context.Context, p string, w io.Writer) error {
@@ -120,21 +212,223 @@ func (fs *FS) Ls(ctx context.Context, p string, fn func(iofs.DirEntry) error) er
 	return nil
 }
 
+// Copy copies the file or directory at src to dst, replacing anything at dst.
+// File data is never rewritten. Within a volume the tree at src is grafted at dst in a single CAS.
+// Across volumes only the blobs missing from the destination store are transferred.
+// Anything at dst is only replaced once the copy is complete.
+// src cannot be the mount point of a volume.
+func (fs *FS) Copy(ctx context.Context, src, dst string) error {
+	src, dst = cleanPath(src), cleanPath(dst)
+	if dst == "" {
+		return errors.New("cannot copy to the root")
+	}
+	if src == dst {
+		return nil
+	}
+	srcRes, err := fs.resolve(ctx, fs.root, src)
+	if err != nil {
+		return err
+	}
+	dstRes, err := fs.resolve(ctx, fs.root, dst)
+	if err != nil {
+		return err
+	}
+	if srcRes.Path == "" {
+		// the volume itself is shared by copying its config, not its contents.
+		return fmt.Errorf("cannot copy volume mounted at %q, copy its config or a path within it", src)
+	}
+	if dstRes.Path == "" {
+		return fmt.Errorf("cannot replace volume mounted at %q", dst)
+	}
+	if srcRes.VM.sameVolume(dstRes.VM) {
+		return srcRes.VM.Copy(ctx, srcRes.Path, dstRes.Path)
+	}
+	srcStore, dstStore := srcRes.VM.vol.Store, dstRes.VM.vol.Store
+	if srcStore.Hash(nil) != dstStore.Hash(nil) {
+		// the stores cannot share blobs, so the data has to be rechunked.
+		// dst is only replaced once the copy is complete.
+		branch, err := fs.copyStream(ctx, src, dstRes.VM, nil, "")
+		if err != nil {
+			return err
+		}
+		return dstRes.VM.graft(ctx, dstRes.Path, *branch)
+	}
+	branch, err := srcRes.VM.Branch(ctx, srcRes.Path)
+	if err != nil {
+		return err
+	}
+	return dstRes.VM.Graft(ctx, dstRes.Path, srcStore, *branch)
+}
+
+// copyStream copies the file or directory at src to p in root, by reading everything out of src
+// and writing it into the store of vm. If root is nil, the copy is put at the root of a new tree.
+// The returned root is not committed to vm.
+func (fs *FS) copyStream(ctx context.Context, src string, vm *volumeMount, root *gotfs.Root, p string) (*gotfs.Root, error) {
+	f, err := fs.Open(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	finfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !finfo.IsDir() {
+		if root == nil {
+			return vm.gotfs.CreateFileRoot(ctx, vm.vol.Store, vm.vol.Store, f)
+		}
+		return vm.putFile(ctx, root, p, f)
+	}
+	ents, err := f.ReadDir(0)
+	if err != nil {
+		return nil, err
+	}
+	if root, err = vm.mkdir(ctx, root, p); err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{}, len(ents))
+	for _, ent := range ents {
+		names[ent.Name()] = struct{}{}
+	}
+	for _, ent := range ents {
+		// directories shadowed by a volume are carried along with the config.
+		if _, exists := names[ent.Name()+".webfs"]; exists && ent.IsDir() {
+			continue
+		}
+		if root, err = fs.copyStream(ctx, path.Join(src, ent.Name()), vm, root, path.Join(p, ent.Name())); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+// Snapshot returns the spec for a read-only volume containing the file or directory at p, as it is now.
+// The spec uses a literal cell, and shares the store of the volume containing p.
+// Volumes with memory stores cannot be snapshotted, since memory stores are not shared between mounts.
+func (fs *FS) Snapshot(ctx context.Context, p string) (*VolumeSpec, error) {
+	res, err := fs.resolve(ctx, fs.root, p)
+	if err != nil {
+		return nil, err
+	}
+	if res.VM.spec.Store.Memory != nil {
+		return nil, fmt.Errorf("cannot snapshot %q, its volume has a memory store which the snapshot could not share", p)
+	}
+	branch, err := res.VM.Branch(ctx, res.Path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(branch)
+	if err != nil {
+		return nil, err
+	}
+	return &VolumeSpec{
+		Cell:  CellSpec{Literal: data},
+		Store: res.VM.spec.Store,
+		Salt:  res.VM.spec.Salt,
+	}, nil
+}
+
+// getVolumeMount returns the mount for the volume described by spec, which is configured at p in parent.
 func (fs *FS) getVolumeMount(ctx context.Context, parent *volumeMount, p string, spec *VolumeSpec) (*volumeMount, error) {
-	vol, err := fs.makeVolume(*spec)
+	mp := mountPoint{parent: parent.fingerprint, path: p}
+	return fs.mounts.getOrCreate(mp, spec.Fingerprint(), func() (*volumeMount, error) {
+		fs.log.Infof("mounting volume at %q", p)
+		return fs.newVolumeMount(*spec)
+	})
+}
+
+func (fs *FS) newVolumeMount(spec VolumeSpec) (*volumeMount, error) {
+	vol, err := fs.makeVolume(spec)
 	if err != nil {
 		return nil, err
 	}
 	var seed [32]byte
 	copy(seed[:], spec.Salt)
 	return &volumeMount{
-		parent: parent,
-		path:   p,
-		vol:    *vol,
-		gotfs:  gotfs.NewOperator(gotfs.WithSeed(&seed), gotfs.WithContentCacheSize(10), gotfs.WithMetaCacheSize(128)),
+		spec:        spec,
+		fingerprint: spec.Fingerprint(),
+		vol:         *vol,
+		gotfs:       gotfs.NewOperator(gotfs.WithSeed(&seed), gotfs.WithContentCacheSize(10), gotfs.WithMetaCacheSize(128)),
+		gotkv:       newMetaOperator(spec, gdat.WithCacheSize(128)),
+		readOnly:    spec.Cell.Literal != nil,
+		conflicts:   fs.config.conflicts,
 	}, nil
 }
 
+// newMetaOperator returns a gotkv.Operator for reading the metadata of gotfs in the volume described by spec.
+// It is configured like the gotkv.Operator inside gotfs, with opts added to its data operator.
+func newMetaOperator(spec VolumeSpec, opts ...gdat.Option) gotkv.Operator {
+	var seed [32]byte
+	copy(seed[:], spec.Salt)
+	var metaSeed [32]byte
+	gdat.DeriveKey(metaSeed[:], &seed, []byte("gotkv"))
+	opts = append([]gdat.Option{gdat.WithSalt(&metaSeed)}, opts...)
+	return gotkv.NewOperator(gotfs.DefaultAverageBlobSizeInfo, gotfs.DefaultMaxBlobSize,
+		gotkv.WithDataOperator(gdat.NewOperator(opts...)),
+		gotkv.WithSeed(&metaSeed),
+	)
+}
+
+// listMounts returns ents, which were read from the directory at p in root, with each volume configured
+// in the directory listed as a directory next to its config. Entries shadowed by a volume are left out.
+// The volumes are only mounted if the info for their entry is requested.
+func (fs *FS) listMounts(ctx context.Context, vm *volumeMount, root gotfs.Root, p string, ents []iofs.DirEntry) ([]iofs.DirEntry, error) {
+	ret := make([]iofs.DirEntry, 0, len(ents))
+	for _, ent := range ents {
+		name := ent.Name()
+		spec, err := loadMountConfig(ctx, vm, root, path.Join(p, name+".webfs"))
+		if err != nil {
+			return nil, err
+		}
+		if spec != nil {
+			continue
+		}
+		ret = append(ret, ent)
+
+		// a volume configured at x.webfs is mounted at x.
+		mountName := strings.TrimSuffix(name, ".webfs")
+		if mountName == name || mountName == "" || !ent.Type().IsRegular() {
+			continue
+		}
+		if spec, err = loadMountConfig(ctx, vm, root, path.Join(p, name)); err != nil {
+			return nil, err
+		} else if spec == nil {
+			continue
+		}
+		mountPath := path.Join(p, mountName)
+		ret = append(ret, &dirEntry{
+			name:  mountName,
+			mode:  iofs.ModeDir | 0o755,
+			mount: true,
+			getInfo: func() (*fileInfo, error) {
+				vm2, err := fs.getVolumeMount(ctx, vm, mountPath, spec)
+				if err != nil {
+					return nil, err
+				}
+				info, err := vm2.Stat(ctx, "")
+				if err != nil {
+					return nil, err
+				}
+				// the info is for the root of the volume, which does not have the name of the mount point.
+				fi := *info.(*fileInfo)
+				fi.name = mountName
+				return &fi, nil
+			},
+		})
+	}
+	return ret, nil
+}
+
+// loadMountConfig returns the spec of the volume configured at p in root, or nil if there is none.
+// Invalid configs are ignored, since they do not mount anything.
+func loadMountConfig(ctx context.Context, vm *volumeMount, root gotfs.Root, p string) (*VolumeSpec, error) {
+	spec, err := loadWebFSConfig(ctx, &vm.gotfs, vm.vol.Store, root, p)
+	if errors.As(err, &ErrBadConfig{}) {
+		return nil, nil
+	}
+	return spec, err
+}
+
 type resolveRes struct {
 	VM   *volumeMount
 	Path string
@@ -152,10 +446,11 @@ func (fs *FS) resolve(ctx context.Context, vm *volumeMount, p string) (*resolveR
 			if err != nil {
 				return nil, err
 			}
+			mountPath := strings.TrimSuffix(configPath, ".webfs")
 			if vs == nil {
+				fs.mounts.unmount(mountPoint{parent: vm.fingerprint, path: mountPath})
 				continue
 			}
-			mountPath := path.Dir(configPath)
 			vm2, err := fs.getVolumeMount(ctx, vm, mountPath, vs)
 			if err != nil {
 				return nil, err
@@ -171,63 +466,223 @@ func (fs *FS) resolve(ctx context.Context, vm *volumeMount, p string) (*resolveR
 }
 
 type volumeMount struct {
-	parent *volumeMount
-	path   string
+	spec        VolumeSpec
+	fingerprint [32]byte
 
 	vol   Volume
 	gotfs gotfs.Operator
+	// gotkv reads the metadata of gotfs directly, it is configured like the gotkv.Operator inside gotfs.
+	gotkv gotkv.Operator
+	// readOnly is set for volumes with literal cells.
+	readOnly bool
+	// conflicts decides how concurrent changes to the same path are merged.
+	conflicts ConflictPolicy
 }
 
-func (v *volumeMount) Open(p string) (*File, error) {
-	return newFile(v, p), nil
+// Open opens the file or directory at p for reading, from a snapshot of the volume.
+func (v *volumeMount) Open(ctx context.Context, p string) (*File, error) {
+	p = cleanPath(p)
+	root, err := readRoot(ctx, v.vol.Cell)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		if p != "" {
+			return nil, iofs.ErrNotExist
+		}
+	} else if _, err := v.gotfs.GetInfo(ctx, v.vol.Store, *root, p); err != nil {
+		return nil, convertError(err)
+	}
+	return newFile(ctx, v, root, p), nil
 }
 
-func (v *volumeMount) PutFile(ctx context.Context, p string, r io.Reader) error {
+func (v *volumeMount) OpenFile(ctx context.Context, p string, flag int, perm iofs.FileMode) (*File, error) {
 	p = cleanPath(p)
-	ms, ds := v.vol.Store, v.vol.Store
-	return modifyRoot(ctx, v.vol.Cell, func(root *gotfs.Root) (*gotfs.Root, error) {
-		var err error
-		if root == nil {
-			root, err = v.gotfs.NewEmpty(ctx, ms)
-			if err != nil {
-				return nil, err
-			}
+	const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_CREATE | os.O_TRUNC | os.O_APPEND
+	if flag&writeFlags == 0 {
+		return v.Open(ctx, p)
+	}
+	if v.readOnly {
+		return nil, ErrReadOnly{Path: p}
+	}
+	if perm == 0 {
+		perm = 0o644
+	}
+	ms := v.vol.Store
+	root, err := readRoot(ctx, v.vol.Cell)
+	if err != nil {
+		return nil, err
+	}
+	var info *gotfs.Info
+	if root != nil {
+		info, err = v.gotfs.GetInfo(ctx, ms, *root, p)
+		if err != nil && !posixfs.IsErrNotExist(err) {
+			return nil, err
 		}
-		root, err = v.gotfs.RemoveAll(ctx, ms, *root, p)
-		if err != nil {
+	}
+	switch {
+	case info == nil && flag&os.O_CREATE == 0:
+		return nil, iofs.ErrNotExist
+	case info != nil && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, iofs.ErrExist
+	case info != nil && !iofs.FileMode(info.Mode).IsRegular():
+		return nil, &iofs.PathError{Op: "open", Path: p, Err: errors.New("not a regular file")}
+	case info == nil || flag&os.O_TRUNC != 0:
+		mode := perm & iofs.ModePerm
+		if info != nil {
+			mode = iofs.FileMode(info.Mode)
+		}
+		excl := flag&os.O_EXCL != 0
+		modify := v.modifyRoot
+		if excl {
+			// merging would replace a file created by another writer, instead of failing.
+			modify = v.modifyRootExcl
+		}
+		if err := modify(ctx, p, func(x *gotfs.Root) (*gotfs.Root, error) {
+			root, err = v.createEmpty(ctx, x, p, mode, excl)
+			return root, err
+		}); err != nil {
 			return nil, err
 		}
-		if p != "" {
-			root, err = v.gotfs.MkdirAll(ctx, ms, *root, parentOf(p))
-			if err != nil {
-				return nil, err
-			}
+		info = &gotfs.Info{Mode: uint32(mode)}
+	}
+	size, err := v.gotfs.SizeOfFile(ctx, ms, *root, p)
+	if err != nil {
+		return nil, err
+	}
+	f := newFile(ctx, v, root, p)
+	f.flag = flag
+	f.w = newFileWriter(iofs.FileMode(info.Mode), int64(size))
+	return f, nil
+}
+
+// createEmpty creates an empty file at p in root, replacing anything already there unless excl is set.
+func (v *volumeMount) createEmpty(ctx context.Context, root *gotfs.Root, p string, mode iofs.FileMode, excl bool) (*gotfs.Root, error) {
+	ms, ds := v.vol.Store, v.vol.Store
+	var err error
+	if root == nil {
+		if root, err = v.gotfs.NewEmpty(ctx, ms); err != nil {
+			return nil, err
 		}
-		root, err = v.gotfs.CreateFile(ctx, ms, ds, *root, p, r)
-		if err != nil {
+	}
+	if excl {
+		if _, err := v.gotfs.GetInfo(ctx, ms, *root, p); err == nil {
+			return nil, iofs.ErrExist
+		} else if !posixfs.IsErrNotExist(err) {
 			return nil, err
 		}
-		return root, nil
+	}
+	b := v.gotfs.NewBuilder(ctx, ms, ds)
+	if err := b.BeginFile("", mode); err != nil {
+		return nil, err
+	}
+	fileRoot, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if root, err = v.place(ctx, *root, p, *fileRoot, now); err != nil {
+		return nil, err
+	}
+	return v.setModTime(ctx, *root, p, now)
+}
+
+func (v *volumeMount) PutFile(ctx context.Context, p string, r io.Reader) error {
+	p = cleanPath(p)
+	return v.modifyRoot(ctx, p, func(root *gotfs.Root) (*gotfs.Root, error) {
+		return v.putFile(ctx, root, p, r)
 	})
 }
 
+func (v *volumeMount) putFile(ctx context.Context, root *gotfs.Root, p string, r io.Reader) (*gotfs.Root, error) {
+	ms, ds := v.vol.Store, v.vol.Store
+	var err error
+	if root == nil {
+		root, err = v.gotfs.NewEmpty(ctx, ms)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fileRoot, err := v.gotfs.CreateFileRoot(ctx, ms, ds, r)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if root, err = v.place(ctx, *root, p, *fileRoot, now); err != nil {
+		return nil, err
+	}
+	return v.setModTime(ctx, *root, p, now)
+}
+
 func (v *volumeMount) Rm(ctx context.Context, p string) error {
-	ms := v.vol.Store
-	return modifyRoot(ctx, v.vol.Cell, func(root *gotfs.Root) (*gotfs.Root, error) {
-		var err error
+	p = cleanPath(p)
+	return v.modifyRoot(ctx, p, func(root *gotfs.Root) (*gotfs.Root, error) {
+		return v.rm(ctx, root, p)
+	})
+}
+
+func (v *volumeMount) rm(ctx context.Context, root *gotfs.Root, p string) (*gotfs.Root, error) {
+	if root == nil {
+		return nil, nil
+	}
+	return v.remove(ctx, *root, p, time.Now())
+}
+
+// Rename moves src to dst in a single CAS.
+// The subtree at src is grafted at dst, so no file data is rewritten.
+func (v *volumeMount) Rename(ctx context.Context, src, dst string) error {
+	src, dst = cleanPath(src), cleanPath(dst)
+	if src == dst {
+		return nil
+	}
+	return v.modifyPaths(ctx, []string{src, dst}, func(root *gotfs.Root) (*gotfs.Root, error) {
+		return v.rename(ctx, root, src, dst)
+	})
+}
+
+func (v *volumeMount) rename(ctx context.Context, root *gotfs.Root, src, dst string) (*gotfs.Root, error) {
+	if src == dst {
+		return root, nil
+	}
+	if src == "" || dst == "" || strings.HasPrefix(dst+"/", src+"/") {
+		return nil, fmt.Errorf("cannot move %q to %q", src, dst)
+	}
+	if root == nil {
+		return nil, iofs.ErrNotExist
+	}
+	ms, ds := v.vol.Store, v.vol.Store
+	branch, err := selectBranch(ctx, &v.gotfs, ms, ds, *root, src)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if root, err = v.remove(ctx, *root, src, now); err != nil {
+		return nil, err
+	}
+	return v.place(ctx, *root, dst, *branch, now)
+}
+
+// Copy copies src to dst in a single CAS, the data at src is shared, not copied.
+func (v *volumeMount) Copy(ctx context.Context, src, dst string) error {
+	src, dst = cleanPath(src), cleanPath(dst)
+	if dst == "" {
+		return fmt.Errorf("cannot copy %q to the root", src)
+	}
+	ms, ds := v.vol.Store, v.vol.Store
+	return v.modifyRoot(ctx, dst, func(root *gotfs.Root) (*gotfs.Root, error) {
 		if root == nil {
-			return nil, nil
+			return nil, iofs.ErrNotExist
 		}
-		root, err = v.gotfs.RemoveAll(ctx, ms, *root, p)
+		branch, err := selectBranch(ctx, &v.gotfs, ms, ds, *root, src)
 		if err != nil {
 			return nil, err
 		}
-		return root, nil
+		return v.place(ctx, *root, dst, *branch, time.Now())
 	})
 }
 
-func (v *volumeMount) Stat(ctx context.Context, p string) (iofs.FileInfo, error) {
-	p = cleanPath(p)
+// Branch returns a root containing everything under p
+func (v *volumeMount) Branch(ctx context.Context, p string) (*gotfs.Root, error) {
 	root, err := readRoot(ctx, v.vol.Cell)
 	if err != nil {
 		return nil, err
@@ -235,50 +690,143 @@ func (v *volumeMount) Stat(ctx context.Context, p string) (iofs.FileInfo, error)
 	if root == nil {
 		return nil, iofs.ErrNotExist
 	}
-	return v.stat(ctx, *root, p)
+	return selectBranch(ctx, &v.gotfs, v.vol.Store, v.vol.Store, *root, p)
 }
 
-func (v *volumeMount) Mkdir(ctx context.Context, p string) error {
+// Graft places branch at p, replacing anything at p.
+// Any blobs reachable from branch which are not in the volume's store are copied from src.
+func (v *volumeMount) Graft(ctx context.Context, p string, src cadata.Store, branch gotfs.Root) error {
 	p = cleanPath(p)
-	ms := v.vol.Store
-	return modifyRoot(ctx, v.vol.Cell, func(root *gotfs.Root) (*gotfs.Root, error) {
+	if v.readOnly {
+		return ErrReadOnly{Path: p}
+	}
+	if err := syncRoot(ctx, v.vol.Store, src, branch); err != nil {
+		return err
+	}
+	return v.graft(ctx, p, branch)
+}
+
+// graft places branch, which must already be in the volume's store, at p.
+func (v *volumeMount) graft(ctx context.Context, p string, branch gotfs.Root) error {
+	return v.modifyRoot(ctx, p, func(root *gotfs.Root) (*gotfs.Root, error) {
 		var err error
 		if root == nil {
-			root, err = v.gotfs.NewEmpty(ctx, ms)
+			root, err = v.gotfs.NewEmpty(ctx, v.vol.Store)
 			if err != nil {
 				return nil, err
 			}
 		}
-		return v.gotfs.MkdirAll(ctx, ms, *root, p)
+		return v.place(ctx, *root, p, branch, time.Now())
 	})
 }
 
-func (v *volumeMount) readDir(ctx context.Context, p string, n int) (ret []iofs.DirEntry, _ error) {
+// sameVolume returns true if v and other refer to the same cell and store.
+func (v *volumeMount) sameVolume(other *volumeMount) bool {
+	return v == other || v.fingerprint == other.fingerprint
+}
+
+func (v *volumeMount) Stat(ctx context.Context, p string) (iofs.FileInfo, error) {
+	p = cleanPath(p)
 	root, err := readRoot(ctx, v.vol.Cell)
 	if err != nil {
 		return nil, err
 	}
+	if root == nil {
+		if p == "" {
+			// the root of an empty volume is an empty directory, as in File.Stat.
+			return &fileInfo{name: ".", mode: iofs.ModeDir | 0o755}, nil
+		}
+		return nil, iofs.ErrNotExist
+	}
+	return v.stat(ctx, *root, p)
+}
+
+func (v *volumeMount) Mkdir(ctx context.Context, p string) error {
+	p = cleanPath(p)
+	return v.modifyRoot(ctx, p, func(root *gotfs.Root) (*gotfs.Root, error) {
+		return v.mkdir(ctx, root, p)
+	})
+}
+
+func (v *volumeMount) mkdir(ctx context.Context, root *gotfs.Root, p string) (*gotfs.Root, error) {
+	var err error
+	if root == nil {
+		root, err = v.gotfs.NewEmpty(ctx, v.vol.Store)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return v.mkdirAll(ctx, *root, p, time.Now())
+}
+
+// Chtimes sets the modification time of the file or directory at p.
+func (v *volumeMount) Chtimes(ctx context.Context, p string, mtime time.Time) error {
+	p = cleanPath(p)
+	return v.modifyRoot(ctx, p, func(root *gotfs.Root) (*gotfs.Root, error) {
+		return v.chtimes(ctx, root, p, mtime)
+	})
+}
+
+func (v *volumeMount) chtimes(ctx context.Context, root *gotfs.Root, p string, mtime time.Time) (*gotfs.Root, error) {
+	if root == nil {
+		return nil, iofs.ErrNotExist
+	}
+	return v.setModTime(ctx, *root, p, mtime)
+}
+
+func (v *volumeMount) chmod(ctx context.Context, root *gotfs.Root, p string, mode iofs.FileMode) (*gotfs.Root, error) {
+	if root == nil {
+		return nil, iofs.ErrNotExist
+	}
+	return v.setMode(ctx, *root, p, mode)
+}
+
+// readDir returns up to n entries of the directory at p, starting after the entry named after.
+// If after is "" it starts from the first entry. If n <= 0 all the remaining entries are returned.
+// Resuming from a name instead of an index means reading a directory in pages does not read the earlier entries again.
+func (v *volumeMount) readDir(ctx context.Context, root *gotfs.Root, p, after string, n int) (ret []iofs.DirEntry, _ error) {
 	if root == nil {
 		if p != "" {
 			return nil, iofs.ErrNotExist
 		}
 		return nil, nil
 	}
-	stopIter := errors.New("stop iteration")
-	if err := v.gotfs.ReadDir(ctx, v.vol.Store, *root, p, func(e gotfs.DirEnt) error {
-		if n > 0 && len(ret) >= n {
-			return stopIter
+	ms := v.vol.Store
+	if _, err := v.gotfs.GetDirInfo(ctx, ms, *root, p); err != nil {
+		return nil, convertError(err)
+	}
+	span := gotfs.SpanForPath(p)
+	dirKey := span.Begin
+	if after != "" {
+		span.Begin = gotfs.SpanForPath(path.Join(p, after)).End
+	}
+	it := v.gotkv.NewIterator(ms, *root, span)
+	var ent gotkv.Entry
+	for n <= 0 || len(ret) < n {
+		if err := it.Next(ctx, &ent); errors.Is(err, gotkv.EOS) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(ent.Key, dirKey) {
+			continue
 		}
+		var info gotfs.Info
+		if err := proto.Unmarshal(ent.Value, &info); err != nil {
+			return nil, err
+		}
+		// skip over everything within the entry.
+		if err := it.Seek(ctx, gotkv.PrefixEnd(ent.Key)); err != nil {
+			return nil, err
+		}
+		name := strings.Trim(string(ent.Key[len(dirKey):]), "/")
 		ret = append(ret, &dirEntry{
-			name: e.Name,
-			mode: e.Mode,
+			name: name,
+			mode: iofs.FileMode(info.Mode),
 			getInfo: func() (*fileInfo, error) {
-				return v.stat(ctx, *root, path.Join(v.path, e.Name))
+				return v.stat(ctx, *root, path.Join(p, name))
 			},
 		})
-		return nil
-	}); err != nil && !errors.Is(err, stopIter) {
-		return nil, err
 	}
 	return ret, nil
 }
@@ -302,7 +850,7 @@ func (v *volumeMount) stat(ctx context.Context, root gotfs.Root, p string) (*fil
 		name:    path.Base(p),
 		mode:    mode,
 		size:    size,
-		modTime: time.Now(),
+		modTime: getModTime(info),
 	}, nil
 }
 
@@ -311,6 +859,10 @@ func readRoot(ctx context.Context, c cells.Cell) (*gotfs.Root, error) {
 	if err != nil {
 		return nil, err
 	}
+	return parseRoot(data)
+}
+
+func parseRoot(data []byte) (*gotfs.Root, error) {
 	if len(data) == 0 {
 		return nil, nil
 	}
@@ -321,26 +873,141 @@ func readRoot(ctx context.Context, c cells.Cell) (*gotfs.Root, error) {
 	return &root, nil
 }
 
-func modifyRoot(ctx context.Context, c cells.Cell, fn func(*gotfs.Root) (*gotfs.Root, error)) error {
-	return cells.Apply(ctx, c, func(x []byte) ([]byte, error) {
-		var xRoot *gotfs.Root
-		if len(x) > 0 {
-			xRoot = &gotfs.Root{}
-			if err := json.Unmarshal(x, xRoot); err != nil {
-				return nil, err
-			}
+func marshalRoot(root *gotfs.Root) ([]byte, error) {
+	if root == nil {
+		return nil, nil
+	}
+	return json.Marshal(root)
+}
+
+// modifyRoot applies fn to the root of the volume, p is the path being changed.
+// If another writer changes the volume first, their changes are merged with the result of fn,
+// using the volume's ConflictPolicy, instead of running fn again.
+func (v *volumeMount) modifyRoot(ctx context.Context, p string, fn func(*gotfs.Root) (*gotfs.Root, error)) error {
+	return v.modifyPaths(ctx, []string{p}, fn)
+}
+
+// modifyPaths is like modifyRoot, for a change to several paths.
+// fn must not change anything outside of paths, except for the directories containing them.
+func (v *volumeMount) modifyPaths(ctx context.Context, paths []string, fn func(*gotfs.Root) (*gotfs.Root, error)) error {
+	if v.readOnly {
+		return ErrReadOnly{Path: paths[0]}
+	}
+	prev, err := cells.GetBytes(ctx, v.vol.Cell)
+	if err != nil {
+		return err
+	}
+	base, err := parseRoot(prev)
+	if err != nil {
+		return err
+	}
+	ours, err := fn(base)
+	if err != nil {
+		return err
+	}
+	return v.commit(ctx, prev, base, ours, paths)
+}
+
+// modifyRootExcl is like modifyRoot, but if another writer changes the volume first fn is run again
+// on their root instead of merging, so checks made by fn, such as whether p exists, are never stale.
+func (v *volumeMount) modifyRootExcl(ctx context.Context, p string, fn func(*gotfs.Root) (*gotfs.Root, error)) error {
+	if v.readOnly {
+		return ErrReadOnly{Path: p}
+	}
+	c := v.vol.Cell
+	prev, err := cells.GetBytes(ctx, c)
+	if err != nil {
+		return err
+	}
+	actual := make([]byte, c.MaxSize())
+	for {
+		base, err := parseRoot(prev)
+		if err != nil {
+			return err
 		}
-		yRoot, err := fn(xRoot)
+		ours, err := fn(base)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if yRoot == nil {
-			return nil, nil
+		next, err := marshalRoot(ours)
+		if err != nil {
+			return err
 		}
-		return json.Marshal(yRoot)
+		swapped, n, err := c.CAS(ctx, actual, prev, next)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			return nil
+		}
+		prev = append([]byte{}, actual[:n]...)
+	}
+}
+
+// commit swaps the volume's root from base, which was read from the cell as prev, to ours.
+// Changes made by other writers since then are merged using the volume's ConflictPolicy.
+// paths are the paths changed from base to ours, see changedPaths.
+func (v *volumeMount) commit(ctx context.Context, prev []byte, base, ours *gotfs.Root, paths []string) error {
+	c := v.vol.Cell
+	actual := make([]byte, c.MaxSize())
+	for {
+		next, err := marshalRoot(ours)
+		if err != nil {
+			return err
+		}
+		swapped, n, err := c.CAS(ctx, actual, prev, next)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			return nil
+		}
+		theirs, err := parseRoot(actual[:n])
+		if err != nil {
+			return err
+		}
+		if ours, err = v.merge(ctx, base, ours, theirs, paths); err != nil {
+			return err
+		}
+		base = theirs
+		prev = append([]byte{}, actual[:n]...)
+	}
+}
+
+// syncRoot copies all the blobs reachable from root, which are not already in dst, from src to dst.
+func syncRoot(ctx context.Context, dst, src cadata.Store, root gotfs.Root) error {
+	return gotfs.Sync(ctx, dst, src, root, func(ref gdat.Ref) error {
+		if exists, err := cadata.Exists(ctx, dst, ref.CID); err != nil {
+			return err
+		} else if exists {
+			return nil
+		}
+		return cadata.Copy(ctx, dst, src, ref.CID)
 	})
 }
 
+// selectBranch returns a root containing everything under p, shifted to the root.
+// It is used instead of gotfs.Operator.Select, which fails to delete the span before p.
+func selectBranch(ctx context.Context, fsop *gotfs.Operator, ms, ds cadata.Store, root gotfs.Root, p string) (*gotfs.Root, error) {
+	if _, err := fsop.GetInfo(ctx, ms, root, p); err != nil {
+		return nil, convertError(err)
+	}
+	span := gotfs.SpanForPath(p)
+	branch, err := fsop.Splice(ctx, ms, ds, []gotfs.Segment{{Span: span, Root: root}})
+	if err != nil {
+		return nil, err
+	}
+	prefix := span.Begin[:len(span.Begin)-1]
+	if !bytes.HasPrefix(branch.First, prefix) {
+		return nil, fmt.Errorf("branch at %q does not have prefix %q", p, prefix)
+	}
+	return &gotfs.Root{
+		Ref:   branch.Ref,
+		Depth: branch.Depth,
+		First: append([]byte{}, branch.First[len(prefix):]...),
+	}, nil
+}
+
 func cleanPath(x string) string {
 	x = strings.Trim(x, "/")
 	return x
@@ -373,6 +1040,8 @@ func loadWebFSConfig(ctx context.Context, fsop *gotfs.Operator, s cadata.Store,
 	info, err := fsop.GetInfo(ctx, s, root, p)
 	if posixfs.IsErrNotExist(err) {
 		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
 	if !posixfs.FileMode(info.Mode).IsRegular() {
 		return nil, nil
@@ -3,10 +3,23 @@ package webfs
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"io"
+	iofs "io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/brendoncarroll/go-state/cadata"
+	"github.com/brendoncarroll/go-state/posixfs"
 	"github.com/stretchr/testify/require"
+
+	"github.com/brendoncarroll/webfs/pkg/cells/gotcells"
+	"github.com/brendoncarroll/webfs/pkg/stores/httpstore"
 )
 
 func TestPotConfigPaths(t *testing.T) {
@@ -41,11 +54,334 @@ func TestPutCat(t *testing.T) {
 	require.Equal(t, testData, buf.String())
 }
 
+func TestRename(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	testData := "my test data"
+	require.NoError(t, wfs.PutFile(ctx, "a/b/test", strings.NewReader(testData)))
+	require.NoError(t, wfs.Rename(ctx, "a/b", "c"))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, wfs.Cat(ctx, "c/test", buf))
+	require.Equal(t, testData, buf.String())
+	require.ErrorIs(t, wfs.Cat(ctx, "a/b/test", buf), iofs.ErrNotExist)
+	require.ErrorIs(t, wfs.Rename(ctx, "a/b", "d"), iofs.ErrNotExist)
+	require.Error(t, wfs.Rename(ctx, "c", "c/d"))
+
+	require.NoError(t, wfs.Mkdir(ctx, "c/zzz"))
+	require.NoError(t, wfs.Rename(ctx, "c", "e"))
+	require.NoError(t, wfs.Rename(ctx, "e/test", "test2"))
+	buf.Reset()
+	require.NoError(t, wfs.Cat(ctx, "test2", buf))
+	require.Equal(t, testData, buf.String())
+	var names []string
+	require.NoError(t, wfs.Ls(ctx, "", func(de iofs.DirEntry) error {
+		names = append(names, de.Name())
+		return nil
+	}))
+	require.Equal(t, []string{"a", "e", "test2"}, names)
+}
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	testData := "my test data"
+	require.NoError(t, wfs.PutFile(ctx, "a/b/test", strings.NewReader(testData)))
+	require.NoError(t, wfs.Copy(ctx, "a", "c"))
+	require.NoError(t, wfs.Copy(ctx, "a/b/test", "a/b/test2"))
+
+	for _, p := range []string{"a/b/test", "a/b/test2", "c/b/test"} {
+		buf := &bytes.Buffer{}
+		require.NoError(t, wfs.Cat(ctx, p, buf))
+		require.Equal(t, testData, buf.String())
+	}
+	require.ErrorIs(t, wfs.Copy(ctx, "d", "e"), iofs.ErrNotExist)
+}
+
+func TestCopyVolumes(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	testData := "my test data"
+	putVolumeSpec(t, wfs, "vol.webfs", newTestVolumeSpec(t))
+	require.NoError(t, wfs.PutFile(ctx, "a/b/test", strings.NewReader(testData)))
+	require.NoError(t, wfs.Copy(ctx, "a", "vol/a"))
+	require.NoError(t, wfs.Rename(ctx, "a/b", "vol/b"))
+
+	for _, p := range []string{"vol/a/b/test", "vol/b/test"} {
+		buf := &bytes.Buffer{}
+		require.NoError(t, wfs.Cat(ctx, p, buf))
+		require.Equal(t, testData, buf.String())
+	}
+	require.ErrorIs(t, wfs.Cat(ctx, "a/b/test", &bytes.Buffer{}), iofs.ErrNotExist)
+}
+
+func TestCopyHashes(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	putVolumeSpec(t, wfs, "vol.webfs", VolumeSpec{
+		Cell:  CellSpec{Memory: &struct{}{}},
+		Store: StoreSpec{Memory: &struct{}{}},
+		Salt:  []byte("nested"),
+	})
+	res, err := wfs.resolve(ctx, wfs.root, "vol")
+	require.NoError(t, err)
+	// a store using a different hash cannot share blobs with the root volume.
+	res.VM.vol.Store = cadata.NewMem(func(x []byte) cadata.ID {
+		return cadata.ID(sha256.Sum256(x))
+	}, MaxBlobSize)
+
+	require.NoError(t, wfs.PutFile(ctx, "a/b/test", strings.NewReader("my test data")))
+	require.NoError(t, wfs.PutFile(ctx, "vol/a/old", strings.NewReader("old data")))
+	require.ErrorIs(t, wfs.Copy(ctx, "missing", "vol/a"), iofs.ErrNotExist)
+	require.Equal(t, "old data", catString(t, wfs, "vol/a/old"))
+
+	require.NoError(t, wfs.Copy(ctx, "a", "vol/a"))
+	require.Equal(t, "my test data", catString(t, wfs, "vol/a/b/test"))
+	require.ErrorIs(t, wfs.Cat(ctx, "vol/a/old", &bytes.Buffer{}), iofs.ErrNotExist)
+	require.NoError(t, wfs.Copy(ctx, "a/b/test", "vol/test"))
+	require.Equal(t, "my test data", catString(t, wfs, "vol/test"))
+}
+
+func TestRenameHashes(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	putVolumeSpec(t, wfs, "vol.webfs", VolumeSpec{
+		Cell:  CellSpec{Memory: &struct{}{}},
+		Store: StoreSpec{Memory: &struct{}{}},
+		Salt:  []byte("nested"),
+	})
+	res, err := wfs.resolve(ctx, wfs.root, "vol")
+	require.NoError(t, err)
+	res.VM.vol.Store = cadata.NewMem(func(x []byte) cadata.ID {
+		return cadata.ID(sha256.Sum256(x))
+	}, MaxBlobSize)
+
+	require.NoError(t, wfs.PutFile(ctx, "a/test", strings.NewReader("my test data")))
+	require.NoError(t, wfs.PutFile(ctx, "vol/a/old", strings.NewReader("old data")))
+	require.ErrorIs(t, wfs.Rename(ctx, "missing", "vol/a"), iofs.ErrNotExist)
+	require.Equal(t, "old data", catString(t, wfs, "vol/a/old"))
+
+	require.NoError(t, wfs.Rename(ctx, "a", "vol/a"))
+	require.Equal(t, "my test data", catString(t, wfs, "vol/a/test"))
+	require.ErrorIs(t, wfs.Cat(ctx, "a/test", &bytes.Buffer{}), iofs.ErrNotExist)
+}
+
+func TestCopyMountPoint(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	putVolumeSpec(t, wfs, "vol.webfs", newTestVolumeSpec(t))
+	require.NoError(t, wfs.PutFile(ctx, "vol/test", strings.NewReader("my test data")))
+	require.Error(t, wfs.Copy(ctx, "vol", "c"))
+	require.Error(t, wfs.Copy(ctx, "", "c"))
+	_, err := wfs.Stat(ctx, "c")
+	require.ErrorIs(t, err, iofs.ErrNotExist)
+}
+
+func TestNestedVolume(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	memSpec := VolumeSpec{
+		Cell:  CellSpec{Memory: &struct{}{}},
+		Store: StoreSpec{Memory: &struct{}{}},
+		Salt:  []byte("nested"),
+	}
+	putVolumeSpec(t, wfs, "dir/nested.webfs", memSpec)
+	require.NoError(t, wfs.PutFile(ctx, "dir/nested/test", strings.NewReader("my test data")))
+	require.Equal(t, "my test data", catString(t, wfs, "dir/nested/test"))
+	require.Equal(t, 2, wfs.mounts.len())
+
+	// the volume is listed next to its config.
+	for _, n := range []int{0, 1} {
+		f, err := wfs.Open(ctx, "dir")
+		require.NoError(t, err)
+		var ents []iofs.DirEntry
+		for {
+			page, err := f.ReadDir(n)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			ents = append(ents, page...)
+			if n == 0 {
+				break
+			}
+		}
+		require.Len(t, ents, 2)
+		require.Equal(t, "nested.webfs", ents[0].Name())
+		require.Equal(t, "nested", ents[1].Name())
+		require.True(t, ents[1].IsDir())
+		info, err := ents[1].Info()
+		require.NoError(t, err)
+		require.Equal(t, "nested", info.Name())
+		require.True(t, info.IsDir())
+	}
+
+	res, err := wfs.resolve(ctx, wfs.root, "dir/nested/test")
+	require.NoError(t, err)
+	require.Equal(t, "test", res.Path)
+	require.Equal(t, memSpec.Fingerprint(), res.VM.fingerprint)
+
+	// changing the config mounts a different volume
+	memSpec.Salt = []byte("nested2")
+	putVolumeSpec(t, wfs, "dir/nested.webfs", memSpec)
+	require.ErrorIs(t, wfs.Cat(ctx, "dir/nested/test", &bytes.Buffer{}), iofs.ErrNotExist)
+	require.Equal(t, 2, wfs.mounts.len())
+
+	require.NoError(t, wfs.Remove(ctx, "dir/nested.webfs"))
+	require.ErrorIs(t, wfs.Cat(ctx, "dir/nested/test", &bytes.Buffer{}), iofs.ErrNotExist)
+	require.Equal(t, 1, wfs.mounts.len())
+}
+
+func TestHTTPStore(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	store := cadata.NewMem(Hash, MaxBlobSize)
+	srv := httptest.NewServer(httpstore.NewServer(store))
+	t.Cleanup(srv.Close)
+	putVolumeSpec(t, wfs, "remote.webfs", VolumeSpec{
+		Cell:  CellSpec{Memory: &struct{}{}},
+		Store: StoreSpec{HTTP: HTTPStoreSpec{URL: srv.URL}},
+	})
+	require.NoError(t, wfs.PutFile(ctx, "remote/test", strings.NewReader("my test data")))
+	require.Equal(t, "my test data", catString(t, wfs, "remote/test"))
+	require.NotZero(t, store.Len())
+}
+
+func TestBaselineSpecs(t *testing.T) {
+	wfs := newTestWebFS(t)
+	// the first version of the spec always included an http store, with an empty URL.
+	const memJSON = `{"cell":{"memory":{}},"store":{"memory":{},"http":{"url":"","headers":null}},"salt":null}`
+	spec, err := ParseVolumeSpec([]byte(memJSON))
+	require.NoError(t, err)
+	require.Equal(t, [32]byte(Hash([]byte(memJSON))), spec.Fingerprint())
+	store, err := wfs.makeStore(spec.Store)
+	require.NoError(t, err)
+	require.IsType(t, &cadata.MemStore{}, store)
+
+	spec, err = ParseVolumeSpec([]byte(`{"cell":{"memory":{}},"store":{"http":{"url":"","headers":null},"ipfs":{}},"salt":null}`))
+	require.NoError(t, err)
+	store, err = wfs.makeStore(spec.Store)
+	require.NoError(t, err)
+	require.NotEqual(t, reflect.TypeOf(&httpstore.Client{}), reflect.TypeOf(store))
+
+	spec, err = ParseVolumeSpec([]byte(`{"cell":{"memory":{}},"store":{"http":{"url":"","headers":null}},"salt":null}`))
+	require.NoError(t, err)
+	_, err = wfs.makeStore(spec.Store)
+	require.Error(t, err)
+
+	// a got_branch cell keeps its snapshots with the data, unless its vc_store has a store set.
+	spec, err = ParseVolumeSpec([]byte(`{"cell":{"got_branch":{"inner":{"memory":{}},"vc_store":{"http":{"url":"","headers":null}}}},"store":{"memory":{},"http":{"url":"","headers":null}},"salt":null}`))
+	require.NoError(t, err)
+	require.Equal(t, spec.Store, vcStoreSpec(*spec))
+	vol, err := wfs.makeVolume(*spec)
+	require.NoError(t, err)
+	require.Equal(t, vol.Store, vol.Cell.(*gotcells.BranchCell).VCStore())
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	// the snapshot shares the store of the volume it was taken from, so it can't be a memory store.
+	require.NoError(t, wfs.PutFile(ctx, "dir/a.txt", strings.NewReader("memory")))
+	_, err := wfs.Snapshot(ctx, "dir")
+	require.Error(t, err)
+	putVolumeSpec(t, wfs, "vol.webfs", newTestVolumeSpec(t))
+	require.NoError(t, wfs.PutFile(ctx, "vol/dir/a.txt", strings.NewReader("version 1")))
+	spec, err := wfs.Snapshot(ctx, "vol/dir")
+	require.NoError(t, err)
+	putVolumeSpec(t, wfs, "snap.webfs", *spec)
+	require.NoError(t, wfs.PutFile(ctx, "vol/dir/a.txt", strings.NewReader("version 2")))
+	require.Equal(t, "version 1", catString(t, wfs, "snap/a.txt"))
+	require.Equal(t, "version 2", catString(t, wfs, "vol/dir/a.txt"))
+
+	var roErr ErrReadOnly
+	require.ErrorAs(t, wfs.PutFile(ctx, "snap/a.txt", strings.NewReader("x")), &roErr)
+	require.Equal(t, "a.txt", roErr.Path)
+	require.ErrorAs(t, wfs.Remove(ctx, "snap/a.txt"), &roErr)
+	require.ErrorAs(t, wfs.Rename(ctx, "snap/a.txt", "b.txt"), &roErr)
+	_, err = wfs.OpenFile(ctx, "snap/a.txt", os.O_RDWR, 0)
+	require.ErrorAs(t, err, &roErr)
+	// the snapshot can still be copied out of.
+	require.NoError(t, wfs.Copy(ctx, "snap/a.txt", "b.txt"))
+	require.Equal(t, "version 1", catString(t, wfs, "b.txt"))
+
+	// invalid literals are rejected instead of panicking.
+	putVolumeSpec(t, wfs, "bad.webfs", VolumeSpec{
+		Cell:  CellSpec{Literal: []byte(`"not a root"`)},
+		Store: StoreSpec{Memory: &struct{}{}},
+	})
+	require.Error(t, wfs.Cat(ctx, "bad/a.txt", &bytes.Buffer{}))
+}
+
+func TestGotBranch(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+	spec := newTestVolumeSpec(t)
+	spec.Cell = CellSpec{GotBranch: &GotBranchCellSpec{Inner: spec.Cell}}
+	putVolumeSpec(t, wfs, "branch.webfs", spec)
+	require.NoError(t, wfs.PutFile(ctx, "branch/a.txt", strings.NewReader("1")))
+	require.NoError(t, wfs.PutFile(ctx, "branch/a.txt", strings.NewReader("2")))
+	require.Equal(t, "2", catString(t, wfs, "branch/a.txt"))
+
+	res, err := wfs.resolve(ctx, wfs.root, "branch/a.txt")
+	require.NoError(t, err)
+	head, err := res.VM.vol.Cell.(*gotcells.BranchCell).Head(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), head.N)
+	require.Len(t, head.Parents, 1)
+}
+
+func TestModTime(t *testing.T) {
+	ctx := context.Background()
+	wfs := newTestWebFS(t)
+
+	before := time.Now()
+	require.NoError(t, wfs.PutFile(ctx, "a/b.txt", strings.NewReader("hello")))
+	finfo, err := wfs.Stat(ctx, "a/b.txt")
+	require.NoError(t, err)
+	require.False(t, finfo.ModTime().Before(before))
+	dinfo, err := wfs.Stat(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, finfo.ModTime(), dinfo.ModTime())
+
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 7, time.UTC)
+	require.NoError(t, wfs.Chtimes(ctx, "a/b.txt", mtime))
+	finfo, err = wfs.Stat(ctx, "a/b.txt")
+	require.NoError(t, err)
+	require.True(t, mtime.Equal(finfo.ModTime()))
+
+	// renaming the file keeps its mtime, but touches both directories.
+	require.NoError(t, wfs.Rename(ctx, "a/b.txt", "c/d.txt"))
+	finfo, err = wfs.Stat(ctx, "c/d.txt")
+	require.NoError(t, err)
+	require.True(t, mtime.Equal(finfo.ModTime()))
+	dinfo, err = wfs.Stat(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, dinfo.ModTime().After(mtime))
+}
+
 func newTestWebFS(t testing.TB) *FS {
 	fs, err := New(VolumeSpec{
 		Cell:  CellSpec{Memory: &struct{}{}},
 		Store: StoreSpec{Memory: &struct{}{}},
-	})
+	}, WithPosixFS(posixfs.NewOSFS()))
 	require.NoError(t, err)
 	return fs
 }
+
+// newTestVolumeSpec returns a spec for a volume which persists in a temporary directory.
+func newTestVolumeSpec(t testing.TB) VolumeSpec {
+	dir := t.TempDir()
+	cellPath := filepath.Join(dir, "cell")
+	storePath := filepath.Join(dir, "store")
+	return VolumeSpec{
+		Cell:  CellSpec{File: &cellPath},
+		Store: StoreSpec{FS: &storePath},
+	}
+}
+
+func putVolumeSpec(t testing.TB, wfs *FS, p string, spec VolumeSpec) {
+	data, err := MarshalVolumeSpec(spec)
+	require.NoError(t, err)
+	require.NoError(t, wfs.PutFile(context.Background(), p, bytes.NewReader(data)))
+}
@@ -0,0 +1,251 @@
+package webfs9p
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Version is the only version of the protocol which is served.
+const Version = "9P2000.L"
+
+const (
+	// headerSize is the size of the size, type and tag fields at the start of every message.
+	headerSize = 4 + 1 + 2
+	// maxMsize is the largest message size negotiated with a client.
+	maxMsize = 1 << 20
+	// noFid is used in place of a fid, when there is none.
+	noFid = ^uint32(0)
+	// noTag is the tag of Tversion messages.
+	noTag = ^uint16(0)
+)
+
+// message types, the response to each T message is the following R message.
+const (
+	msgRlerror   = 7
+	msgTstatfs   = 8
+	msgTlopen    = 12
+	msgTlcreate  = 14
+	msgTrename   = 20
+	msgTgetattr  = 24
+	msgTsetattr  = 26
+	msgTreaddir  = 40
+	msgTfsync    = 50
+	msgTlock     = 52
+	msgTgetlock  = 54
+	msgTmkdir    = 72
+	msgTrenameat = 74
+	msgTunlinkat = 76
+	msgTversion  = 100
+	msgTattach   = 104
+	msgTflush    = 108
+	msgTwalk     = 110
+	msgTread     = 116
+	msgTwrite    = 118
+	msgTclunk    = 120
+	msgTremove   = 122
+)
+
+// qid types
+const (
+	qtDir  = 0x80
+	qtFile = 0x00
+)
+
+// open flags, these are the values used by Linux, whatever the platform of the server.
+const (
+	oRDONLY  = 0o0
+	oWRONLY  = 0o1
+	oRDWR    = 0o2
+	oACCMODE = 0o3
+	oCREAT   = 0o100
+	oEXCL    = 0o200
+	oTRUNC   = 0o1000
+	oAPPEND  = 0o2000
+)
+
+// attributes in Tsetattr
+const (
+	setattrMode     = 0x1
+	setattrSize     = 0x8
+	setattrMtime    = 0x20
+	setattrMtimeSet = 0x100
+)
+
+const (
+	// getattrBasic is the set of attributes which are returned by Tgetattr.
+	getattrBasic = 0x7ff
+	// atRemoveDir is the flag to Tunlinkat for removing a directory.
+	atRemoveDir = 0x200
+	// lockSuccess is the status of a granted lock.
+	lockSuccess = 0
+	// lockUnlocked is the type of lock returned by Tgetlock when there are no conflicting locks.
+	lockUnlocked = 2
+	// statfsMagic is the type of filesystem returned by Tstatfs.
+	statfsMagic = 0x01021997
+)
+
+// directory entry types
+const (
+	dtDir = 4
+	dtReg = 8
+)
+
+// errno is an error number, as sent to clients in Rlerror.
+// The values are the ones used by Linux, whatever the platform of the server.
+type errno uint32
+
+const (
+	eNOENT     errno = 2
+	eINTR      errno = 4
+	eIO        errno = 5
+	eBADF      errno = 9
+	eACCES     errno = 13
+	eEXIST     errno = 17
+	eNOTDIR    errno = 20
+	eISDIR     errno = 21
+	eINVAL     errno = 22
+	eROFS      errno = 30
+	eNOTEMPTY  errno = 39
+	ePROTO     errno = 71
+	eOPNOTSUPP errno = 95
+)
+
+func (e errno) Error() string {
+	return fmt.Sprintf("9p: errno %d", uint32(e))
+}
+
+type qid struct {
+	Type    uint8
+	Version uint32
+	Path    uint64
+}
+
+// encoder appends the fields of a message to a buffer.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) u8(x uint8) {
+	e.buf = append(e.buf, x)
+}
+
+func (e *encoder) u16(x uint16) {
+	e.buf = append(e.buf, byte(x), byte(x>>8))
+}
+
+func (e *encoder) u32(x uint32) {
+	e.buf = append(e.buf, byte(x), byte(x>>8), byte(x>>16), byte(x>>24))
+}
+
+func (e *encoder) u64(x uint64) {
+	e.u32(uint32(x))
+	e.u32(uint32(x >> 32))
+}
+
+func (e *encoder) str(x string) {
+	e.u16(uint16(len(x)))
+	e.buf = append(e.buf, x...)
+}
+
+func (e *encoder) qid(q qid) {
+	e.u8(q.Type)
+	e.u32(q.Version)
+	e.u64(q.Path)
+}
+
+// data appends x with its length as a 4 byte prefix.
+func (e *encoder) data(x []byte) {
+	e.u32(uint32(len(x)))
+	e.buf = append(e.buf, x...)
+}
+
+var errShortMessage = errors.New("9p: message is too short")
+
+// decoder reads the fields of a message.
+// After the first error, all fields are zero, and the error is returned by err.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.buf) < n {
+		d.err = errShortMessage
+		return nil
+	}
+	x := d.buf[:n]
+	d.buf = d.buf[n:]
+	return x
+}
+
+func (d *decoder) u8() uint8 {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) u16() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) u32() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) u64() uint64 {
+	if b := d.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) str() string {
+	return string(d.next(int(d.u16())))
+}
+
+func (d *decoder) qid() qid {
+	return qid{Type: d.u8(), Version: d.u32(), Path: d.u64()}
+}
+
+// data reads a byte slice with a 4 byte length prefix.
+func (d *decoder) data() []byte {
+	return d.next(int(d.u32()))
+}
+
+// readMsg reads a message which is no larger than msize.
+func readMsg(r io.Reader, msize uint32) (typ uint8, tag uint16, body []byte, err error) {
+	var hdr [headerSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(hdr[:4])
+	if size < headerSize || size > msize {
+		return 0, 0, nil, fmt.Errorf("9p: invalid message size %d", size)
+	}
+	body = make([]byte, size-headerSize)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, nil, err
+	}
+	return hdr[4], binary.LittleEndian.Uint16(hdr[5:]), body, nil
+}
+
+func writeMsg(w io.Writer, typ uint8, tag uint16, body []byte) error {
+	buf := make([]byte, headerSize, headerSize+len(body))
+	binary.LittleEndian.PutUint32(buf, uint32(headerSize+len(body)))
+	buf[4] = typ
+	binary.LittleEndian.PutUint16(buf[5:], tag)
+	_, err := w.Write(append(buf, body...))
+	return err
+}
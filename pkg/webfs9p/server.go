@@ -0,0 +1,851 @@
+// Package webfs9p serves a WebFS filesystem over 9P2000.L.
+package webfs9p
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	iofs "io/fs"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+)
+
+// Serve serves fs over 9P2000.L on l, until l is closed.
+func Serve(ctx context.Context, l net.Listener, fs *webfs.FS) error {
+	return NewServer(ctx, fs).Serve(l)
+}
+
+// Server serves a webfs.FS to 9P2000.L clients.
+//
+// Fids refer to paths, so a fid for a file which is moved by another fid or client no longer refers to it.
+// Writes to a file are committed when its fid is clunked or fsynced.
+type Server struct {
+	ctx context.Context
+	fs  *webfs.FS
+}
+
+// NewServer returns a Server for fs.
+// ctx is used for all of the operations on fs.
+func NewServer(ctx context.Context, fs *webfs.FS) *Server {
+	return &Server{ctx: ctx, fs: fs}
+}
+
+// Serve accepts connections on l and serves each of them, until l is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer c.Close()
+			if err := s.ServeConn(c); err != nil {
+				logrus.Warnf("9p: %v: %v", c.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// ServeConn serves requests from rw until it is closed.
+// Requests are handled one at a time, in the order they are received.
+func (s *Server) ServeConn(rw io.ReadWriter) error {
+	c := &conn{
+		s:     s,
+		msize: maxMsize,
+		fids:  map[uint32]*fid{},
+	}
+	defer c.clunkAll()
+	for {
+		typ, tag, body, err := readMsg(rw, c.msize)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		e := &encoder{}
+		rtyp := typ + 1
+		if err := c.handle(typ, &decoder{buf: body}, e); err != nil {
+			rtyp = msgRlerror
+			e = &encoder{}
+			e.u32(uint32(toErrno(err)))
+		}
+		if err := writeMsg(rw, rtyp, tag, e.buf); err != nil {
+			return err
+		}
+	}
+}
+
+type conn struct {
+	s     *Server
+	msize uint32
+	fids  map[uint32]*fid
+}
+
+// fid is a reference to a file, held by the client.
+type fid struct {
+	path string
+	uid  uint32
+
+	// open is set once the fid has been opened with Tlopen or Tlcreate.
+	open bool
+	// file is set for regular files which are open.
+	file *webfs.File
+	// dirents is the listing of an open directory, it is read at offset 0 and reused for the rest of the entries.
+	dirents []dirent
+}
+
+type dirent struct {
+	name string
+	qid  qid
+}
+
+func (c *conn) handle(typ uint8, d *decoder, e *encoder) error {
+	var err error
+	switch typ {
+	case msgTversion:
+		err = c.version(d, e)
+	case msgTattach:
+		err = c.attach(d, e)
+	case msgTwalk:
+		err = c.walk(d, e)
+	case msgTlopen:
+		err = c.lopen(d, e)
+	case msgTlcreate:
+		err = c.lcreate(d, e)
+	case msgTread:
+		err = c.read(d, e)
+	case msgTwrite:
+		err = c.write(d, e)
+	case msgTclunk:
+		err = c.clunk(d, e)
+	case msgTremove:
+		err = c.remove(d, e)
+	case msgTgetattr:
+		err = c.getattr(d, e)
+	case msgTsetattr:
+		err = c.setattr(d, e)
+	case msgTreaddir:
+		err = c.readdir(d, e)
+	case msgTmkdir:
+		err = c.mkdir(d, e)
+	case msgTrename:
+		err = c.rename(d, e)
+	case msgTrenameat:
+		err = c.renameat(d, e)
+	case msgTunlinkat:
+		err = c.unlinkat(d, e)
+	case msgTfsync:
+		err = c.fsync(d, e)
+	case msgTstatfs:
+		err = c.statfs(d, e)
+	case msgTlock:
+		err = c.lock(d, e)
+	case msgTgetlock:
+		err = c.getlock(d, e)
+	case msgTflush:
+		// requests are handled in order, so the flushed request has already been responded to.
+		d.u16()
+	default:
+		// authentication, links, special files and extended attributes are not supported.
+		return eOPNOTSUPP
+	}
+	if err != nil {
+		return err
+	}
+	return d.err
+}
+
+func (c *conn) version(d *decoder, e *encoder) error {
+	msize, version := d.u32(), d.str()
+	if d.err != nil {
+		return d.err
+	}
+	// a new version starts a new session.
+	c.clunkAll()
+	if msize > maxMsize {
+		msize = maxMsize
+	}
+	if msize < headerSize+64 {
+		return eINVAL
+	}
+	c.msize = msize
+	if !strings.HasPrefix(version, Version) {
+		version = "unknown"
+	} else {
+		version = Version
+	}
+	e.u32(msize)
+	e.str(version)
+	return nil
+}
+
+func (c *conn) attach(d *decoder, e *encoder) error {
+	fidNum, _, _, _, uid := d.u32(), d.u32(), d.str(), d.str(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	if _, exists := c.fids[fidNum]; exists {
+		return eBADF
+	}
+	info, err := c.s.fs.Stat(c.s.ctx, "")
+	if err != nil {
+		return err
+	}
+	c.fids[fidNum] = &fid{path: "", uid: uid}
+	e.qid(makeQid("", info))
+	return nil
+}
+
+// walk creates a new fid by walking names from an existing fid.
+// If only some of the names exist, the qids for those are returned, and the new fid is not created.
+func (c *conn) walk(d *decoder, e *encoder) error {
+	fidNum, newFidNum, n := d.u32(), d.u32(), d.u16()
+	names := make([]string, 0, n)
+	for i := 0; i < int(n); i++ {
+		names = append(names, d.str())
+	}
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return err
+	}
+	if _, exists := c.fids[newFidNum]; exists && newFidNum != fidNum {
+		return eBADF
+	}
+	if f.open && newFidNum == fidNum {
+		return eBADF
+	}
+	p := f.path
+	var qids []qid
+	for i, name := range names {
+		if name == ".." {
+			p = parentPath(p)
+		} else {
+			if err := checkName(name); err != nil {
+				return err
+			}
+			p = path.Join(p, name)
+		}
+		info, err := c.s.fs.Stat(c.s.ctx, p)
+		if err != nil {
+			if i == 0 {
+				return err
+			}
+			break
+		}
+		qids = append(qids, makeQid(p, info))
+	}
+	if len(qids) == len(names) {
+		c.fids[newFidNum] = &fid{path: p, uid: f.uid}
+	}
+	e.u16(uint16(len(qids)))
+	for _, q := range qids {
+		e.qid(q)
+	}
+	return nil
+}
+
+func (c *conn) lopen(d *decoder, e *encoder) error {
+	fidNum, flags := d.u32(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return err
+	}
+	if f.open {
+		return eBADF
+	}
+	info, err := c.s.fs.Stat(c.s.ctx, f.path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if flags&oACCMODE != oRDONLY {
+			return eISDIR
+		}
+	} else {
+		file, err := c.s.fs.OpenFile(c.s.ctx, f.path, osFlags(flags)&^(os.O_CREATE|os.O_EXCL), 0)
+		if err != nil {
+			return err
+		}
+		f.file = file
+	}
+	f.open = true
+	e.qid(makeQid(f.path, info))
+	e.u32(0)
+	return nil
+}
+
+// lcreate creates a file in the directory of a fid, and changes the fid to the opened file.
+func (c *conn) lcreate(d *decoder, e *encoder) error {
+	fidNum, name, flags, mode, _ := d.u32(), d.str(), d.u32(), d.u32(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return err
+	}
+	if f.open {
+		return eBADF
+	}
+	if err := checkName(name); err != nil {
+		return err
+	}
+	p := path.Join(f.path, name)
+	file, err := c.s.fs.OpenFile(c.s.ctx, p, osFlags(flags)|os.O_CREATE, iofs.FileMode(mode)&iofs.ModePerm)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.path, f.file, f.open = p, file, true
+	e.qid(makeQid(p, info))
+	e.u32(0)
+	return nil
+}
+
+func (c *conn) read(d *decoder, e *encoder) error {
+	fidNum, offset, count := d.u32(), d.u64(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getOpenFile(fidNum)
+	if err != nil {
+		return err
+	}
+	if limit := c.msize - headerSize - 4; count > limit {
+		count = limit
+	}
+	buf := make([]byte, count)
+	n, err := f.file.ReadAt(buf, int64(offset))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	e.data(buf[:n])
+	return nil
+}
+
+func (c *conn) write(d *decoder, e *encoder) error {
+	fidNum, offset, data := d.u32(), d.u64(), d.data()
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getOpenFile(fidNum)
+	if err != nil {
+		return err
+	}
+	n, err := f.file.WriteAt(data, int64(offset))
+	if err != nil {
+		return err
+	}
+	e.u32(uint32(n))
+	return nil
+}
+
+// clunk forgets a fid, committing any writes made through it.
+func (c *conn) clunk(d *decoder, e *encoder) error {
+	fidNum := d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return err
+	}
+	delete(c.fids, fidNum)
+	return f.closeFile()
+}
+
+// remove removes the file or empty directory of a fid, and clunks the fid, even if the removal fails.
+func (c *conn) remove(d *decoder, e *encoder) error {
+	fidNum := d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return err
+	}
+	delete(c.fids, fidNum)
+	if err := f.closeFile(); err != nil {
+		return err
+	}
+	info, err := c.s.fs.Stat(c.s.ctx, f.path)
+	if err != nil {
+		return err
+	}
+	return c.removePath(f.path, info.IsDir())
+}
+
+func (c *conn) getattr(d *decoder, e *encoder) error {
+	fidNum, _ := d.u32(), d.u64()
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return err
+	}
+	info, err := c.stat(f)
+	if err != nil {
+		return err
+	}
+	mode := uint32(info.Mode().Perm())
+	nlink := uint64(1)
+	if info.IsDir() {
+		mode |= 0o040000
+		nlink = 2
+	} else {
+		mode |= 0o100000
+	}
+	size := uint64(info.Size())
+	mtime := info.ModTime()
+	e.u64(getattrBasic)
+	e.qid(makeQid(f.path, info))
+	e.u32(mode)
+	e.u32(f.uid)
+	e.u32(f.uid)
+	e.u64(nlink)
+	e.u64(0)                  // rdev
+	e.u64(size)               // size
+	e.u64(4096)               // blksize
+	e.u64((size + 511) / 512) // blocks
+	// WebFS only stores modification times, so they are used for the access and change times as well.
+	for i := 0; i < 3; i++ {
+		e.u64(uint64(mtime.Unix()))
+		e.u64(uint64(mtime.Nanosecond()))
+	}
+	e.u64(0) // btime
+	e.u64(0)
+	e.u64(0) // gen
+	e.u64(0) // data_version
+	return nil
+}
+
+// setattr changes the mode, size and modification time of a file.
+// Owners and access times are not stored by WebFS, so changes to them are ignored.
+func (c *conn) setattr(d *decoder, e *encoder) error {
+	fidNum, valid, mode, _, _, size := d.u32(), d.u32(), d.u32(), d.u32(), d.u32(), d.u64()
+	_, _, mtimeSec, mtimeNsec := d.u64(), d.u64(), d.u64(), d.u64()
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return err
+	}
+	if valid&setattrMode != 0 {
+		if err := c.s.fs.Txn(c.s.ctx, func(tx *webfs.Tx) error {
+			return tx.Chmod(f.path, iofs.FileMode(mode)&iofs.ModePerm)
+		}); err != nil {
+			return err
+		}
+	}
+	if valid&setattrSize != 0 {
+		if err := c.truncate(f, int64(size)); err != nil {
+			return err
+		}
+	}
+	if valid&setattrMtime != 0 {
+		mtime := time.Now()
+		if valid&setattrMtimeSet != 0 {
+			mtime = time.Unix(int64(mtimeSec), int64(mtimeNsec))
+		}
+		if err := c.s.fs.Chtimes(c.s.ctx, f.path, mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncate changes the size of the file of f, through its open file if it has one.
+func (c *conn) truncate(f *fid, size int64) error {
+	if f.file != nil {
+		if err := f.file.Truncate(size); err == nil || !errors.Is(err, iofs.ErrPermission) {
+			return err
+		}
+	}
+	file, err := c.s.fs.OpenFile(c.s.ctx, f.path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readdir returns the entries of an open directory, starting at offset.
+// The offset of each entry is the offset of the entry after it.
+func (c *conn) readdir(d *decoder, e *encoder) error {
+	fidNum, offset, count := d.u32(), d.u64(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return err
+	}
+	if !f.open || f.file != nil {
+		return eBADF
+	}
+	if offset == 0 || f.dirents == nil {
+		if f.dirents, err = c.list(f.path); err != nil {
+			return err
+		}
+	}
+	if limit := c.msize - headerSize - 4; count > limit {
+		count = limit
+	}
+	ents := &encoder{}
+	for i := offset; i < uint64(len(f.dirents)); i++ {
+		ent := f.dirents[i]
+		if len(ents.buf)+13+8+1+2+len(ent.name) > int(count) {
+			if len(ents.buf) == 0 {
+				// the entry does not fit in the space the client has for it.
+				return eINVAL
+			}
+			break
+		}
+		ents.qid(ent.qid)
+		ents.u64(i + 1)
+		if ent.qid.Type == qtDir {
+			ents.u8(dtDir)
+		} else {
+			ents.u8(dtReg)
+		}
+		ents.str(ent.name)
+	}
+	e.data(ents.buf)
+	return nil
+}
+
+// list returns the entries in the directory p, sorted by name.
+func (c *conn) list(p string) ([]dirent, error) {
+	dirents := []dirent{}
+	if err := c.s.fs.Ls(c.s.ctx, p, func(ent iofs.DirEntry) error {
+		info, err := ent.Info()
+		if err != nil {
+			return err
+		}
+		dirents = append(dirents, dirent{name: ent.Name(), qid: makeQid(path.Join(p, ent.Name()), info)})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(dirents, func(i, j int) bool {
+		return dirents[i].name < dirents[j].name
+	})
+	return dirents, nil
+}
+
+func (c *conn) mkdir(d *decoder, e *encoder) error {
+	fidNum, name, _, _ := d.u32(), d.str(), d.u32(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	p, err := c.childPath(fidNum, name)
+	if err != nil {
+		return err
+	}
+	if _, err := c.s.fs.Stat(c.s.ctx, p); err == nil {
+		return eEXIST
+	}
+	if err := c.s.fs.Mkdir(c.s.ctx, p); err != nil {
+		return err
+	}
+	info, err := c.s.fs.Stat(c.s.ctx, p)
+	if err != nil {
+		return err
+	}
+	e.qid(makeQid(p, info))
+	return nil
+}
+
+// rename moves the file of a fid into a directory, and changes the fid to the new path.
+func (c *conn) rename(d *decoder, e *encoder) error {
+	fidNum, dirFidNum, name := d.u32(), d.u32(), d.str()
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return err
+	}
+	dst, err := c.childPath(dirFidNum, name)
+	if err != nil {
+		return err
+	}
+	if err := c.s.fs.Rename(c.s.ctx, f.path, dst); err != nil {
+		return err
+	}
+	f.path = dst
+	return nil
+}
+
+func (c *conn) renameat(d *decoder, e *encoder) error {
+	oldDirFid, oldName, newDirFid, newName := d.u32(), d.str(), d.u32(), d.str()
+	if d.err != nil {
+		return d.err
+	}
+	src, err := c.childPath(oldDirFid, oldName)
+	if err != nil {
+		return err
+	}
+	dst, err := c.childPath(newDirFid, newName)
+	if err != nil {
+		return err
+	}
+	return c.s.fs.Rename(c.s.ctx, src, dst)
+}
+
+func (c *conn) unlinkat(d *decoder, e *encoder) error {
+	fidNum, name, flags := d.u32(), d.str(), d.u32()
+	if d.err != nil {
+		return d.err
+	}
+	p, err := c.childPath(fidNum, name)
+	if err != nil {
+		return err
+	}
+	return c.removePath(p, flags&atRemoveDir != 0)
+}
+
+// removePath removes the file at p if dir is false, or the empty directory at p if dir is true.
+func (c *conn) removePath(p string, dir bool) error {
+	if p == "" {
+		return eBADF
+	}
+	info, err := c.s.fs.Stat(c.s.ctx, p)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir() && !dir:
+		return eISDIR
+	case !info.IsDir() && dir:
+		return eNOTDIR
+	case info.IsDir():
+		if err := c.s.fs.Ls(c.s.ctx, p, func(iofs.DirEntry) error {
+			return eNOTEMPTY
+		}); err != nil {
+			return err
+		}
+	}
+	return c.s.fs.Remove(c.s.ctx, p)
+}
+
+func (c *conn) fsync(d *decoder, e *encoder) error {
+	fidNum := d.u32()
+	// the datasync field was added to the protocol later, and may be missing.
+	d.buf = nil
+	if d.err != nil {
+		return d.err
+	}
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return err
+	}
+	if f.file != nil {
+		return f.file.Sync()
+	}
+	return nil
+}
+
+func (c *conn) statfs(d *decoder, e *encoder) error {
+	if _, err := c.getFid(d.u32()); err != nil {
+		return err
+	}
+	e.u32(statfsMagic)
+	e.u32(4096) // bsize
+	e.u64(0)    // blocks
+	e.u64(0)    // bfree
+	e.u64(0)    // bavail
+	e.u64(0)    // files
+	e.u64(0)    // ffree
+	e.u64(0)    // fsid
+	e.u32(255)  // namelen
+	return nil
+}
+
+// lock grants every lock, locks are not shared between clients.
+func (c *conn) lock(d *decoder, e *encoder) error {
+	if _, err := c.getFid(d.u32()); err != nil {
+		return err
+	}
+	d.buf = nil
+	e.u8(lockSuccess)
+	return nil
+}
+
+func (c *conn) getlock(d *decoder, e *encoder) error {
+	fidNum, _, start, length, procID, clientID := d.u32(), d.u8(), d.u64(), d.u64(), d.u32(), d.str()
+	if d.err != nil {
+		return d.err
+	}
+	if _, err := c.getFid(fidNum); err != nil {
+		return err
+	}
+	e.u8(lockUnlocked)
+	e.u64(start)
+	e.u64(length)
+	e.u32(procID)
+	e.str(clientID)
+	return nil
+}
+
+func (c *conn) getFid(fidNum uint32) (*fid, error) {
+	f, exists := c.fids[fidNum]
+	if !exists {
+		return nil, eBADF
+	}
+	return f, nil
+}
+
+// getOpenFile returns a fid which has been opened as a regular file.
+func (c *conn) getOpenFile(fidNum uint32) (*fid, error) {
+	f, err := c.getFid(fidNum)
+	if err != nil {
+		return nil, err
+	}
+	if !f.open {
+		return nil, eBADF
+	}
+	if f.file == nil {
+		return nil, eISDIR
+	}
+	return f, nil
+}
+
+// childPath returns the path of name in the directory of a fid.
+func (c *conn) childPath(dirFidNum uint32, name string) (string, error) {
+	f, err := c.getFid(dirFidNum)
+	if err != nil {
+		return "", err
+	}
+	if err := checkName(name); err != nil {
+		return "", err
+	}
+	return path.Join(f.path, name), nil
+}
+
+// stat returns information about the file of f, including writes which have not been committed.
+func (c *conn) stat(f *fid) (iofs.FileInfo, error) {
+	if f.file != nil {
+		return f.file.Stat()
+	}
+	return c.s.fs.Stat(c.s.ctx, f.path)
+}
+
+func (c *conn) clunkAll() {
+	for fidNum, f := range c.fids {
+		if err := f.closeFile(); err != nil {
+			logrus.Warnf("9p: closing %q: %v", f.path, err)
+		}
+		delete(c.fids, fidNum)
+	}
+}
+
+// closeFile closes the file of f, if it has one.
+func (f *fid) closeFile() error {
+	if f.file == nil {
+		return nil
+	}
+	file := f.file
+	f.file = nil
+	return file.Close()
+}
+
+// checkName returns an error if name is not a single path element.
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return eINVAL
+	}
+	return nil
+}
+
+func parentPath(p string) string {
+	p = path.Dir(p)
+	if p == "." || p == "/" {
+		return ""
+	}
+	return p
+}
+
+// makeQid returns the qid of the file at p.
+// The path of the qid is a hash of p, so it is the same for every client, and when the server restarts.
+func makeQid(p string, info iofs.FileInfo) qid {
+	h := fnv.New64a()
+	h.Write([]byte(p))
+	q := qid{Type: qtFile, Path: h.Sum64()}
+	if info.IsDir() {
+		q.Type = qtDir
+	}
+	return q
+}
+
+// osFlags converts open flags from the protocol to the flags used by the os package.
+// Appends are not passed through, because the client provides the offset of every write.
+func osFlags(flags uint32) int {
+	var x int
+	switch flags & oACCMODE {
+	case oWRONLY:
+		x = os.O_WRONLY
+	case oRDWR:
+		x = os.O_RDWR
+	default:
+		x = os.O_RDONLY
+	}
+	if flags&oCREAT != 0 {
+		x |= os.O_CREATE
+	}
+	if flags&oEXCL != 0 {
+		x |= os.O_EXCL
+	}
+	if flags&oTRUNC != 0 {
+		x |= os.O_TRUNC
+	}
+	return x
+}
+
+func toErrno(err error) errno {
+	var e errno
+	var roErr webfs.ErrReadOnly
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, errShortMessage):
+		return ePROTO
+	case errors.Is(err, iofs.ErrNotExist):
+		return eNOENT
+	case errors.Is(err, iofs.ErrExist):
+		return eEXIST
+	case errors.Is(err, iofs.ErrPermission):
+		return eACCES
+	case errors.As(err, &roErr):
+		return eROFS
+	case errors.Is(err, context.Canceled):
+		return eINTR
+	default:
+		return eIO
+	}
+}
@@ -0,0 +1,331 @@
+package webfs9p
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+	"github.com/brendoncarroll/webfs/pkg/webfstest"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	wfs := webfstest.NewMemFS(t)
+	c := newTestClient(t, wfs)
+	const root = 0
+
+	// create, write and read back.
+	_, err := c.walk(root, 1)
+	require.NoError(t, err)
+	_, err = c.lcreate(1, "a.txt", oRDWR, 0o644)
+	require.NoError(t, err)
+	require.NoError(t, c.write(1, 0, "hello"))
+	require.NoError(t, c.write(1, 5, " world"))
+	require.NoError(t, c.clunk(1))
+	require.Equal(t, "hello world", webfstest.Cat(t, wfs, "a.txt"))
+
+	qids, err := c.walk(root, 2, "a.txt")
+	require.NoError(t, err)
+	require.Len(t, qids, 1)
+	require.Equal(t, uint8(qtFile), qids[0].Type)
+	require.NoError(t, c.lopen(2, oRDONLY))
+	data, err := c.read(2, 6, 100)
+	require.NoError(t, err)
+	require.Equal(t, "world", data)
+	size, mode, err := c.getattr(2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), size)
+	require.Equal(t, uint32(0o100644), mode)
+	require.ErrorIs(t, c.write(2, 0, "x"), eACCES)
+	require.NoError(t, c.clunk(2))
+
+	// truncate through setattr.
+	_, err = c.walk(root, 2, "a.txt")
+	require.NoError(t, err)
+	require.NoError(t, c.setattrSize(2, 5))
+	require.NoError(t, c.clunk(2))
+	require.Equal(t, "hello", webfstest.Cat(t, wfs, "a.txt"))
+
+	// directories.
+	_, err = c.mkdir(root, "sub")
+	require.NoError(t, err)
+	_, err = c.mkdir(root, "sub")
+	require.ErrorIs(t, err, eEXIST)
+	_, err = c.walk(root, 3, "sub")
+	require.NoError(t, err)
+	require.NoError(t, c.renameat(root, "a.txt", 3, "b.txt"))
+	require.Equal(t, "hello", webfstest.Cat(t, wfs, "sub/b.txt"))
+	require.Equal(t, []string{"sub"}, c.readdir(t, root))
+	require.Equal(t, []string{"b.txt"}, c.readdir(t, 3))
+
+	// walking stops at the first missing name.
+	_, err = c.walk(root, 4, "missing")
+	require.ErrorIs(t, err, eNOENT)
+	qids, err = c.walk(root, 4, "sub", "missing")
+	require.NoError(t, err)
+	require.Len(t, qids, 1)
+	require.ErrorIs(t, c.clunk(4), eBADF)
+	qids, err = c.walk(root, 4, "..", "sub", "..")
+	require.NoError(t, err)
+	require.Len(t, qids, 3)
+	require.Equal(t, qids[0], qids[2])
+	_, err = c.walk(root, 5, "sub/b.txt")
+	require.ErrorIs(t, err, eINVAL)
+
+	require.ErrorIs(t, c.unlinkat(root, "sub", atRemoveDir), eNOTEMPTY)
+	require.ErrorIs(t, c.unlinkat(3, "b.txt", atRemoveDir), eNOTDIR)
+	require.NoError(t, c.unlinkat(3, "b.txt", 0))
+	require.NoError(t, c.unlinkat(root, "sub", atRemoveDir))
+	_, err = wfs.Stat(ctx, "sub")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestServerNested(t *testing.T) {
+	ctx := context.Background()
+	wfs := webfstest.NewMemFS(t)
+	webfstest.PutMemVolume(t, wfs, "vol")
+	require.NoError(t, wfs.PutFile(ctx, "vol/x.txt", strings.NewReader("x")))
+	c := newTestClient(t, wfs)
+
+	require.Equal(t, []string{"vol", "vol.webfs"}, c.readdir(t, 0))
+	_, err := c.walk(0, 1, "vol", "x.txt")
+	require.NoError(t, err)
+	require.NoError(t, c.lopen(1, oRDONLY))
+	data, err := c.read(1, 0, 100)
+	require.NoError(t, err)
+	require.Equal(t, "x", data)
+}
+
+// client is a minimal 9P2000.L client, which sends one request at a time.
+type client struct {
+	conn net.Conn
+	tag  uint16
+}
+
+// newTestClient serves wfs on one end of a pipe, and returns a client attached as fid 0 on the other end.
+func newTestClient(t *testing.T, wfs *webfs.FS) *client {
+	c1, c2 := net.Pipe()
+	go NewServer(context.Background(), wfs).ServeConn(c2)
+	t.Cleanup(func() { c1.Close() })
+	c := &client{conn: c1}
+
+	e := &encoder{}
+	e.u32(8192)
+	e.str(Version)
+	d, err := c.rpcTag(noTag, msgTversion, e)
+	require.NoError(t, err)
+	require.Equal(t, uint32(8192), d.u32())
+	require.Equal(t, Version, d.str())
+
+	e = &encoder{}
+	e.u32(0)
+	e.u32(noFid)
+	e.str("user")
+	e.str("")
+	e.u32(1000)
+	_, err = c.rpc(msgTattach, e)
+	require.NoError(t, err)
+	return c
+}
+
+func (c *client) rpc(typ uint8, e *encoder) (*decoder, error) {
+	c.tag++
+	return c.rpcTag(c.tag, typ, e)
+}
+
+func (c *client) rpcTag(tag uint16, typ uint8, e *encoder) (*decoder, error) {
+	if err := writeMsg(c.conn, typ, tag, e.buf); err != nil {
+		return nil, err
+	}
+	rtyp, rtag, body, err := readMsg(c.conn, maxMsize)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{buf: body}
+	switch {
+	case rtag != tag:
+		return nil, ePROTO
+	case rtyp == msgRlerror:
+		return nil, errno(d.u32())
+	case rtyp != typ+1:
+		return nil, ePROTO
+	}
+	return d, nil
+}
+
+func (c *client) walk(fid, newFid uint32, names ...string) ([]qid, error) {
+	e := &encoder{}
+	e.u32(fid)
+	e.u32(newFid)
+	e.u16(uint16(len(names)))
+	for _, name := range names {
+		e.str(name)
+	}
+	d, err := c.rpc(msgTwalk, e)
+	if err != nil {
+		return nil, err
+	}
+	qids := make([]qid, d.u16())
+	for i := range qids {
+		qids[i] = d.qid()
+	}
+	return qids, d.err
+}
+
+func (c *client) lopen(fid, flags uint32) error {
+	e := &encoder{}
+	e.u32(fid)
+	e.u32(flags)
+	_, err := c.rpc(msgTlopen, e)
+	return err
+}
+
+func (c *client) lcreate(fid uint32, name string, flags, mode uint32) (qid, error) {
+	e := &encoder{}
+	e.u32(fid)
+	e.str(name)
+	e.u32(flags)
+	e.u32(mode)
+	e.u32(0)
+	d, err := c.rpc(msgTlcreate, e)
+	if err != nil {
+		return qid{}, err
+	}
+	return d.qid(), d.err
+}
+
+func (c *client) read(fid uint32, offset uint64, count uint32) (string, error) {
+	e := &encoder{}
+	e.u32(fid)
+	e.u64(offset)
+	e.u32(count)
+	d, err := c.rpc(msgTread, e)
+	if err != nil {
+		return "", err
+	}
+	return string(d.data()), d.err
+}
+
+func (c *client) write(fid uint32, offset uint64, data string) error {
+	e := &encoder{}
+	e.u32(fid)
+	e.u64(offset)
+	e.data([]byte(data))
+	d, err := c.rpc(msgTwrite, e)
+	if err != nil {
+		return err
+	}
+	if n := d.u32(); int(n) != len(data) {
+		return eIO
+	}
+	return d.err
+}
+
+func (c *client) clunk(fid uint32) error {
+	e := &encoder{}
+	e.u32(fid)
+	_, err := c.rpc(msgTclunk, e)
+	return err
+}
+
+func (c *client) getattr(fid uint32) (size uint64, mode uint32, err error) {
+	e := &encoder{}
+	e.u32(fid)
+	e.u64(getattrBasic)
+	d, err := c.rpc(msgTgetattr, e)
+	if err != nil {
+		return 0, 0, err
+	}
+	d.u64()
+	d.qid()
+	mode = d.u32()
+	d.u32()
+	d.u32()
+	d.u64()
+	d.u64()
+	size = d.u64()
+	return size, mode, d.err
+}
+
+func (c *client) setattrSize(fid uint32, size uint64) error {
+	e := &encoder{}
+	e.u32(fid)
+	e.u32(setattrSize)
+	e.u32(0)
+	e.u32(0)
+	e.u32(0)
+	e.u64(size)
+	for i := 0; i < 4; i++ {
+		e.u64(0)
+	}
+	_, err := c.rpc(msgTsetattr, e)
+	return err
+}
+
+func (c *client) mkdir(fid uint32, name string) (qid, error) {
+	e := &encoder{}
+	e.u32(fid)
+	e.str(name)
+	e.u32(0o755)
+	e.u32(0)
+	d, err := c.rpc(msgTmkdir, e)
+	if err != nil {
+		return qid{}, err
+	}
+	return d.qid(), d.err
+}
+
+func (c *client) renameat(oldDir uint32, oldName string, newDir uint32, newName string) error {
+	e := &encoder{}
+	e.u32(oldDir)
+	e.str(oldName)
+	e.u32(newDir)
+	e.str(newName)
+	_, err := c.rpc(msgTrenameat, e)
+	return err
+}
+
+func (c *client) unlinkat(dir uint32, name string, flags uint32) error {
+	e := &encoder{}
+	e.u32(dir)
+	e.str(name)
+	e.u32(flags)
+	_, err := c.rpc(msgTunlinkat, e)
+	return err
+}
+
+// readdir lists the directory of fid, through a new fid, in small pages.
+func (c *client) readdir(t testing.TB, fid uint32) []string {
+	const dirFid = 100
+	_, err := c.walk(fid, dirFid)
+	require.NoError(t, err)
+	defer c.clunk(dirFid)
+	require.NoError(t, c.lopen(dirFid, oRDONLY))
+	var names []string
+	var offset uint64
+	for {
+		e := &encoder{}
+		e.u32(dirFid)
+		e.u64(offset)
+		e.u32(48)
+		d, err := c.rpc(msgTreaddir, e)
+		require.NoError(t, err)
+		ents := &decoder{buf: d.data()}
+		require.NoError(t, d.err)
+		if len(ents.buf) == 0 {
+			return names
+		}
+		for len(ents.buf) > 0 {
+			ents.qid()
+			offset = ents.u64()
+			ents.u8()
+			names = append(names, ents.str())
+			require.NoError(t, ents.err)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package webfscmd
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"os"
@@ -19,27 +18,37 @@ func newAddCmd() *cobra.Command {
 		Args:  cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			dst, src := args[0], args[1]
-			return importPath(ctx, wfs, dst, src)
+			// everything is committed at once, so readers never see a partial import.
+			return wfs.Txn(ctx, func(tx *webfs.Tx) error {
+				return importPath(tx, dst, src)
+			})
 		},
 	}
 }
 
-func importPath(ctx context.Context, wfs *webfs.FS, dst, src string) error {
+func importPath(tx *webfs.Tx, dst, src string) error {
 	fmt.Println("importing", src, "->", dst)
 	finfo, err := os.Stat(src)
 	if err != nil {
 		return err
 	}
 	if finfo.IsDir() {
-		if err := wfs.Mkdir(ctx, dst); err != nil {
+		if err := tx.Mkdir(dst); err != nil {
+			return err
+		}
+		if err := importDir(tx, dst, src); err != nil {
+			return err
+		}
+	} else {
+		if err := importFile(tx, dst, src); err != nil {
 			return err
 		}
-		return importDir(ctx, wfs, dst, src)
 	}
-	return importFile(ctx, wfs, dst, src)
+	// directories are touched by adding their contents, so this must happen last.
+	return tx.Chtimes(dst, finfo.ModTime())
 }
 
-func importDir(ctx context.Context, wfs *webfs.FS, dst, src string) error {
+func importDir(tx *webfs.Tx, dst, src string) error {
 	f, err := os.Open(src)
 	if err != nil {
 		return err
@@ -57,14 +66,14 @@ func importDir(ctx context.Context, wfs *webfs.FS, dst, src string) error {
 	for _, name := range names {
 		subsrc := filepath.Join(src, name)
 		subdst := path.Join(dst, name)
-		if err := importPath(ctx, wfs, subdst, subsrc); err != nil {
+		if err := importPath(tx, subdst, subsrc); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func importFile(ctx context.Context, wfs *webfs.FS, dst, src string) error {
+func importFile(tx *webfs.Tx, dst, src string) error {
 	f, err := os.Open(src)
 	if err != nil {
 		return err
@@ -74,5 +83,5 @@ func importFile(ctx context.Context, wfs *webfs.FS, dst, src string) error {
 			log.Println(err)
 		}
 	}()
-	return wfs.PutFile(ctx, dst, f)
+	return tx.PutFile(dst, f)
 }
@@ -0,0 +1,20 @@
+package webfscmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+func newCpCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "cp <src> <dst>",
+		Short: "Copies the object at args[0] to args[1]",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, dst := args[0], args[1]
+			log.Println("copying", src, "->", dst)
+			return wfs.Copy(ctx, src, dst)
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package webfscmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "export <path> [--format tar|zip] [-o file]",
+		Short: "Writes a file or directory as an archive",
+		Args:  cobra.ExactArgs(1),
+	}
+	format := c.Flags().String("format", string(webfs.ArchiveTar), "the archive format: tar or zip")
+	output := c.Flags().StringP("output", "o", "", "the file to write the archive to, instead of stdout")
+	c.RunE = func(cmd *cobra.Command, args []string) error {
+		if *output == "" {
+			return wfs.Export(ctx, args[0], cmd.OutOrStdout(), webfs.ArchiveFormat(*format))
+		}
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		if err := wfs.Export(ctx, args[0], f, webfs.ArchiveFormat(*format)); err != nil {
+			f.Close()
+			os.Remove(*output)
+			return err
+		}
+		return f.Close()
+	}
+	return c
+}
+
+func newImportCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "import <dst> <archive>",
+		Short: "Adds the contents of a tar, tar.gz or zip archive to a directory",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			size, err := f.Seek(0, io.SeekEnd)
+			if err != nil {
+				return err
+			}
+			return wfs.Import(ctx, args[0], f, size)
+		},
+	}
+}
@@ -0,0 +1,34 @@
+package webfscmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newFsckCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "fsck [path]",
+		Short: "Checks that the data at a path is intact",
+		Args:  cobra.RangeArgs(0, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var p string
+			if len(args) > 0 {
+				p = args[0]
+			}
+			problems, err := wfs.Check(ctx, p)
+			if err != nil {
+				return err
+			}
+			for _, problem := range problems {
+				if _, err := fmt.Fprintln(cmd.OutOrStdout(), problem.String()); err != nil {
+					return err
+				}
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("found %d problems", len(problems))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package webfscmd
+
+import (
+	"fmt"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+	"github.com/spf13/cobra"
+)
+
+func newGCCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "gc [--dry-run]",
+		Short: "Deletes blobs which are no longer reachable from the root volume",
+		Long: `Deletes blobs which are no longer reachable from the root volume.
+
+Blobs are kept if they are reachable from the current contents or the history of the root volume,
+or of any volume configured within it.
+Snapshots are only kept while their specs are stored in a .webfs file within the root volume,
+so the blobs of a snapshot which was printed but never stored can be deleted.`,
+		Args: cobra.NoArgs,
+	}
+	dryRun := c.Flags().Bool("dry-run", false, "report what would be deleted, without deleting anything")
+	c.RunE = func(cmd *cobra.Command, args []string) error {
+		var opts []webfs.GCOption
+		if *dryRun {
+			opts = append(opts, webfs.GCDryRun())
+		}
+		res, err := wfs.GC(ctx, opts...)
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		if *dryRun {
+			_, err = fmt.Fprintf(out, "%d blobs (%d bytes) can be deleted, %d blobs in use\n", res.Unreachable, res.UnreachableBytes, res.Reachable)
+		} else {
+			_, err = fmt.Fprintf(out, "%d blobs deleted, %d blobs in use\n", res.Unreachable, res.Reachable)
+		}
+		return err
+	}
+	return c
+}
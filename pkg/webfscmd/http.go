@@ -1,14 +1,13 @@
 package webfscmd
 
 import (
-	"context"
-	iofs "io/fs"
 	"net"
 	"net/http"
 
-	"github.com/brendoncarroll/webfs/pkg/webfs"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+
+	"github.com/brendoncarroll/webfs/pkg/webfshttp"
 )
 
 func newHTTPCmd() *cobra.Command {
@@ -18,7 +17,7 @@ func newHTTPCmd() *cobra.Command {
 	}
 	laddr := c.Flags().String("addr", "127.0.0.1:7007", "--addr 127.0.0.1:12345")
 	c.RunE = func(cmd *cobra.Command, args []string) error {
-		h := http.FileServer(http.FS(iofsAdapt{wfs}))
+		h := webfshttp.NewHandler(wfs)
 		l, err := net.Listen("tcp", *laddr)
 		if err != nil {
 			return err
@@ -29,11 +28,3 @@ func newHTTPCmd() *cobra.Command {
 	}
 	return c
 }
-
-type iofsAdapt struct {
-	wfs *webfs.FS
-}
-
-func (fs iofsAdapt) Open(p string) (iofs.File, error) {
-	return fs.wfs.Open(context.Background(), p)
-}
@@ -0,0 +1,38 @@
+package webfscmd
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newLogCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "log <path>",
+		Short: "Lists the versions in which a path was changed",
+		Args:  cobra.RangeArgs(0, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var p string
+			if len(args) > 0 {
+				p = args[0]
+			}
+			versions, err := wfs.History(ctx, p)
+			if err != nil {
+				return err
+			}
+			w := bufio.NewWriter(cmd.OutOrStdout())
+			for _, v := range versions {
+				change := "modified"
+				if v.Deleted {
+					change = "deleted"
+				}
+				if _, err := fmt.Fprintf(w, "%8d %-25s %s\n", v.N, v.CreatedAt.Local().Format(time.RFC3339), change); err != nil {
+					return err
+				}
+			}
+			return w.Flush()
+		},
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"bufio"
 	"fmt"
 	"io/fs"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 func newLsCmd() *cobra.Command {
-	return &cobra.Command{
+	var long bool
+	c := &cobra.Command{
 		Use:   "ls",
 		Short: "List files and directories",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -19,8 +21,20 @@ func newLsCmd() *cobra.Command {
 			}
 			w := bufio.NewWriter(cmd.OutOrStdout())
 			if err := wfs.Ls(ctx, p, func(de fs.DirEntry) error {
-				perm := de.Type().Perm()
-				_, err := fmt.Fprintf(w, "%v %-20s\n", perm, de.Name())
+				if !long {
+					perm := de.Type().Perm()
+					_, err := fmt.Fprintf(w, "%v %-20s\n", perm, de.Name())
+					return err
+				}
+				finfo, err := de.Info()
+				if err != nil {
+					return err
+				}
+				mtime := "-"
+				if !finfo.ModTime().IsZero() {
+					mtime = finfo.ModTime().Local().Format(time.RFC3339)
+				}
+				_, err = fmt.Fprintf(w, "%v %12d %-25s %s\n", finfo.Mode(), finfo.Size(), mtime, de.Name())
 				return err
 			}); err != nil {
 				return err
@@ -28,4 +42,6 @@ func newLsCmd() *cobra.Command {
 			return w.Flush()
 		},
 	}
+	c.Flags().BoolVarP(&long, "long", "l", false, "show mode, size and modification time")
+	return c
 }
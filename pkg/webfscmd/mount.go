@@ -7,19 +7,17 @@ import (
 )
 
 func newMountCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "mount",
+	c := &cobra.Command{
+		Use:   "mount --path <dir>",
 		Short: "Mounts a fuse filesystem",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			return errors.New("fuse not yet supported")
-			// if err := setupWfs(); err != nil {
-			// 	return err
-			// }
-			// if len(args) < 1 {
-			// 	return errors.New("must provide path")
-			// }
-			// path := args[0]
-			// return fuseadapt.MountAndRun(wfs, path)
-		},
+		Args:  cobra.NoArgs,
 	}
+	p := c.Flags().String("path", "", "the directory to mount at")
+	c.RunE = func(cmd *cobra.Command, args []string) error {
+		if *p == "" {
+			return errors.New("must provide path")
+		}
+		return mountAndRun(*p)
+	}
+	return c
 }
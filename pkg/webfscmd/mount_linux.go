@@ -0,0 +1,32 @@
+package webfscmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/brendoncarroll/webfs/pkg/webfsfuse"
+)
+
+// mountAndRun mounts wfs at p, and serves it until interrupted.
+func mountAndRun(p string) error {
+	server, err := webfsfuse.Mount(ctx, wfs, p)
+	if err != nil {
+		return err
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	go func() {
+		<-sigs
+		logrus.Infof("unmounting %s", p)
+		if err := server.Unmount(); err != nil {
+			logrus.Errorf("unmounting %s: %v", p, err)
+		}
+	}()
+	logrus.Infof("mounted at %s", p)
+	server.Wait()
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package webfscmd
+
+import "errors"
+
+func mountAndRun(p string) error {
+	return errors.New("fuse is only supported on linux")
+}
@@ -0,0 +1,28 @@
+package webfscmd
+
+import (
+	"net"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/brendoncarroll/webfs/pkg/webfsnfs"
+)
+
+func newNFSCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "nfs",
+		Short: "serve files over NFSv3",
+	}
+	laddr := c.Flags().String("addr", "127.0.0.1:2049", "--addr 127.0.0.1:12345")
+	c.RunE = func(cmd *cobra.Command, args []string) error {
+		l, err := net.Listen("tcp", *laddr)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		logrus.Infof("serving NFS on %v", l.Addr())
+		return webfsnfs.Serve(ctx, l, wfs)
+	}
+	return c
+}
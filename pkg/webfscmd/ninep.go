@@ -0,0 +1,28 @@
+package webfscmd
+
+import (
+	"net"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs9p"
+)
+
+func new9PCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "9p",
+		Short: "serve files over 9P2000.L",
+	}
+	laddr := c.Flags().String("addr", "127.0.0.1:5640", "--addr 127.0.0.1:12345")
+	c.RunE = func(cmd *cobra.Command, args []string) error {
+		l, err := net.Listen("tcp", *laddr)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		logrus.Infof("serving 9P on %v", l.Addr())
+		return webfs9p.Serve(ctx, l, wfs)
+	}
+	return c
+}
@@ -0,0 +1,41 @@
+package webfscmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+	"github.com/spf13/cobra"
+)
+
+func newReplicateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "replicate <src-spec> <dst-spec>",
+		Short: "Copies the contents of one volume to another",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, err := readVolumeSpec(args[0])
+			if err != nil {
+				return err
+			}
+			dst, err := readVolumeSpec(args[1])
+			if err != nil {
+				return err
+			}
+			res, err := wfs.Replicate(ctx, *src, *dst)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "%d blobs (%d bytes) copied\n", res.Copied, res.CopiedBytes)
+			return err
+		},
+	}
+}
+
+func readVolumeSpec(p string) (*webfs.VolumeSpec, error) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	return webfs.ParseVolumeSpec(data)
+}
@@ -0,0 +1,19 @@
+package webfscmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newRestoreCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "restore <path> --at <version>",
+		Short: "Restores a path to how it was in a previous version",
+		Args:  cobra.ExactArgs(1),
+	}
+	at := c.Flags().Uint64("at", 0, "the version to restore, from webfs log")
+	c.MarkFlagRequired("at")
+	c.RunE = func(cmd *cobra.Command, args []string) error {
+		return wfs.Restore(ctx, args[0], *at)
+	}
+	return c
+}
@@ -3,7 +3,6 @@ package webfscmd
 import (
 	"context"
 	"errors"
-	"io/ioutil"
 	"path/filepath"
 
 	"github.com/brendoncarroll/go-state/posixfs"
@@ -24,15 +23,16 @@ func NewRootCmd() *cobra.Command {
 		Use:   "webfs",
 	}
 	rootPath := rootCmd.PersistentFlags().StringP("root", "r", "", "-r root.webfs")
+	onConflict := rootCmd.PersistentFlags().String("on-conflict", webfs.LastWriterWins.String(), "how to resolve concurrent changes to the same path: last-writer-wins, keep-both or fail")
 	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		if *rootPath == "" {
 			return errors.New("must provide a root")
 		}
-		data, err := ioutil.ReadFile(*rootPath)
+		vs, err := readVolumeSpec(*rootPath)
 		if err != nil {
 			return err
 		}
-		vs, err := webfs.ParseVolumeSpec(data)
+		policy, err := webfs.ParseConflictPolicy(*onConflict)
 		if err != nil {
 			return err
 		}
@@ -43,6 +43,7 @@ func NewRootCmd() *cobra.Command {
 		opts := []webfs.Option{
 			webfs.WithPosixFS(posixfs.NewDirFS(fsRoot)),
 			webfs.WithIPFS(ipfsapi.NewShell(ipfsstore.CloudflareURL)),
+			webfs.WithConflictPolicy(policy),
 		}
 		wfs, err = webfs.New(*vs, opts...)
 		return err
@@ -51,6 +52,9 @@ func NewRootCmd() *cobra.Command {
 	for _, c := range []*cobra.Command{
 		newCatCmd(),
 		newHTTPCmd(),
+		newWebDAVCmd(),
+		newNFSCmd(),
+		new9PCmd(),
 		newEditCmd(),
 		newAddCmd(),
 		newLsCmd(),
@@ -59,6 +63,16 @@ func NewRootCmd() *cobra.Command {
 		newTouchCmd(),
 		newMountCmd(),
 		newMvCmd(),
+		newCpCmd(),
+		newSnapshotCmd(),
+		newLogCmd(),
+		newRestoreCmd(),
+		newGCCmd(),
+		newFsckCmd(),
+		newSyncCmd(),
+		newReplicateCmd(),
+		newExportCmd(),
+		newImportCmd(),
 	} {
 		rootCmd.AddCommand(c)
 	}
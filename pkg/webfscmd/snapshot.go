@@ -0,0 +1,32 @@
+package webfscmd
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+	"github.com/spf13/cobra"
+)
+
+func newSnapshotCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "snapshot <path> [dst]",
+		Short: "Creates a read-only volume spec for the current contents of a path",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			spec, err := wfs.Snapshot(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			data, err := webfs.MarshalVolumeSpec(*spec)
+			if err != nil {
+				return err
+			}
+			if len(args) < 2 {
+				_, err := fmt.Fprintln(cmd.OutOrStdout(), string(data))
+				return err
+			}
+			return wfs.PutFile(ctx, args[1], bytes.NewReader(data))
+		},
+	}
+}
@@ -0,0 +1,49 @@
+package webfscmd
+
+import (
+	"fmt"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+	"github.com/spf13/cobra"
+)
+
+func newSyncCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "sync <local> <path> | sync --pull <path> <local>",
+		Short: "Makes a path the same as a local file or directory, transferring only the differences",
+		Args:  cobra.ExactArgs(2),
+	}
+	pull := c.Flags().Bool("pull", false, "sync from WebFS to the local filesystem instead")
+	del := c.Flags().Bool("delete", false, "remove anything from the destination which is not in the source")
+	dryRun := c.Flags().Bool("dry-run", false, "list the changes which would be made, without making them")
+	checksum := c.Flags().BoolP("checksum", "c", false, "compare the contents of files instead of their sizes and modification times")
+	c.RunE = func(cmd *cobra.Command, args []string) error {
+		var opts []webfs.SyncOption
+		if *del {
+			opts = append(opts, webfs.SyncDelete())
+		}
+		if *dryRun {
+			opts = append(opts, webfs.SyncDryRun())
+		}
+		if *checksum {
+			opts = append(opts, webfs.SyncChecksum())
+		}
+		var actions []webfs.SyncAction
+		var err error
+		if *pull {
+			actions, err = wfs.SyncToLocal(ctx, args[0], args[1], opts...)
+		} else {
+			actions, err = wfs.SyncFromLocal(ctx, args[1], args[0], opts...)
+		}
+		if err != nil {
+			return err
+		}
+		for _, a := range actions {
+			if _, err := fmt.Fprintf(cmd.OutOrStdout(), "%-6s %s\n", a.Op, a.Path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,30 @@
+package webfscmd
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/brendoncarroll/webfs/pkg/webfsdav"
+)
+
+func newWebDAVCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "webdav",
+		Short: "serve files over WebDAV",
+	}
+	laddr := c.Flags().String("addr", "127.0.0.1:7008", "--addr 127.0.0.1:12345")
+	c.RunE = func(cmd *cobra.Command, args []string) error {
+		h := webfsdav.NewHandler(wfs)
+		l, err := net.Listen("tcp", *laddr)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+		logrus.Infof("serving WebDAV on http://%v", l.Addr())
+		return http.Serve(l, h)
+	}
+	return c
+}
@@ -0,0 +1,171 @@
+// Package webfsdav serves a WebFS filesystem over WebDAV.
+package webfsdav
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/net/webdav"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+)
+
+var (
+	_ webdav.FileSystem = &FileSystem{}
+	_ webdav.File       = &file{}
+	_ webdav.ETager     = &fileInfo{}
+)
+
+// NewHandler returns a WebDAV handler for fs, with locks held in memory.
+func NewHandler(fs *webfs.FS) *webdav.Handler {
+	return &webdav.Handler{
+		FileSystem: NewFileSystem(fs),
+		LockSystem: webdav.NewMemLS(),
+	}
+}
+
+// FileSystem implements webdav.FileSystem on top of a webfs.FS.
+type FileSystem struct {
+	fs *webfs.FS
+}
+
+// NewFileSystem returns a FileSystem for fs.
+func NewFileSystem(fs *webfs.FS) *FileSystem {
+	return &FileSystem{fs: fs}
+}
+
+// Mkdir creates the directory name, its parent must already exist.
+func (fsys *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	name = cleanName(name)
+	if _, err := fsys.fs.Stat(ctx, name); err == nil {
+		return os.ErrExist
+	} else if !errors.Is(err, iofs.ErrNotExist) {
+		return err
+	}
+	if err := fsys.checkParent(ctx, name); err != nil {
+		return err
+	}
+	return fsys.fs.Mkdir(ctx, name)
+}
+
+// OpenFile opens the file or directory at name.
+// Files are written through to WebFS when they are closed.
+func (fsys *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	name = cleanName(name)
+	if flag&os.O_CREATE != 0 {
+		if err := fsys.checkParent(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	f, err := fsys.fs.OpenFile(ctx, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, fsys: fsys, name: name}, nil
+}
+
+// RemoveAll removes name and everything in it.
+func (fsys *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	name = cleanName(name)
+	if name == "" {
+		return os.ErrInvalid
+	}
+	return fsys.fs.Remove(ctx, name)
+}
+
+// Rename moves oldName to newName, replacing anything at newName.
+func (fsys *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	newName = cleanName(newName)
+	if err := fsys.checkParent(ctx, newName); err != nil {
+		return err
+	}
+	return fsys.fs.Rename(ctx, cleanName(oldName), newName)
+}
+
+func (fsys *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	name = cleanName(name)
+	info, err := fsys.fs.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{FileInfo: info, fsys: fsys, name: name}, nil
+}
+
+// checkParent returns os.ErrNotExist if the directory which would contain name does not exist.
+// WebDAV clients expect creating an entry in a missing directory to fail, instead of creating the directory.
+func (fsys *FileSystem) checkParent(ctx context.Context, name string) error {
+	if name == "" {
+		return nil
+	}
+	info, err := fsys.fs.Stat(ctx, path.Dir("/"+name))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+func cleanName(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+type file struct {
+	*webfs.File
+	fsys *FileSystem
+	name string
+}
+
+func (f *file) Stat() (iofs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{FileInfo: info, fsys: f.fsys, name: f.name}, nil
+}
+
+// Readdir implements http.File.
+func (f *file) Readdir(count int) ([]iofs.FileInfo, error) {
+	ents, err := f.ReadDir(count)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]iofs.FileInfo, 0, len(ents))
+	for _, ent := range ents {
+		info, err := ent.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, &fileInfo{FileInfo: info, fsys: f.fsys, name: path.Join(f.name, ent.Name())})
+	}
+	return infos, nil
+}
+
+// fileInfo implements webdav.ETager, so files have the same ETags as from the HTTP server.
+type fileInfo struct {
+	iofs.FileInfo
+	fsys *FileSystem
+	name string
+}
+
+func (fi *fileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.IsDir() {
+		return "", webdav.ErrNotImplemented
+	}
+	f, err := fi.fsys.fs.Open(ctx, fi.name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	id, err := f.ContentID()
+	if err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(id) + `"`, nil
+}
@@ -0,0 +1,111 @@
+package webfsdav
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brendoncarroll/webfs/pkg/webfstest"
+)
+
+func TestFileSystem(t *testing.T) {
+	ctx := context.Background()
+	fsys := NewFileSystem(webfstest.NewMemFS(t))
+	require.ErrorIs(t, fsys.Mkdir(ctx, "/a/b", 0o755), os.ErrNotExist)
+	require.NoError(t, fsys.Mkdir(ctx, "/a", 0o755))
+	require.ErrorIs(t, fsys.Mkdir(ctx, "/a", 0o755), os.ErrExist)
+
+	f, err := fsys.OpenFile(ctx, "/a/x.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	_, err = fsys.OpenFile(ctx, "/missing/x.txt", os.O_RDWR|os.O_CREATE, 0o644)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	d, err := fsys.OpenFile(ctx, "/a", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	infos, err := d.Readdir(0)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.Equal(t, "x.txt", infos[0].Name())
+	require.Equal(t, int64(5), infos[0].Size())
+
+	require.NoError(t, fsys.Rename(ctx, "/a/x.txt", "/y.txt"))
+	_, err = fsys.Stat(ctx, "/a/x.txt")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.NoError(t, fsys.RemoveAll(ctx, "/a"))
+	_, err = fsys.Stat(ctx, "/a")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = fsys.Stat(ctx, "/y.txt")
+	require.NoError(t, err)
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(NewHandler(webfstest.NewMemFS(t)))
+	defer srv.Close()
+
+	res := do(t, srv, "MKCOL", "/dir", "", nil)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	res = do(t, srv, http.MethodPut, "/dir/a.txt", "hello", nil)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	etag := res.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	res = do(t, srv, http.MethodGet, "/dir/a.txt", "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "hello", readBody(t, res))
+	require.Equal(t, etag, res.Header.Get("ETag"))
+
+	res = do(t, srv, "PROPFIND", "/dir", "", map[string]string{"Depth": "1"})
+	require.Equal(t, http.StatusMultiStatus, res.StatusCode)
+	require.Contains(t, readBody(t, res), "/dir/a.txt")
+
+	res = do(t, srv, "MOVE", "/dir/a.txt", "", map[string]string{"Destination": srv.URL + "/dir/b.txt"})
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	res = do(t, srv, http.MethodGet, "/dir/b.txt", "", nil)
+	require.Equal(t, "hello", readBody(t, res))
+
+	// a locked file cannot be changed without the lock token.
+	lockBody := `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>`
+	res = do(t, srv, "LOCK", "/dir/b.txt", lockBody, nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	token := res.Header.Get("Lock-Token")
+	require.NotEmpty(t, token)
+	res = do(t, srv, http.MethodPut, "/dir/b.txt", "changed", nil)
+	require.Equal(t, http.StatusLocked, res.StatusCode)
+	res = do(t, srv, http.MethodPut, "/dir/b.txt", "changed", map[string]string{"If": "(" + token + ")"})
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	res = do(t, srv, "UNLOCK", "/dir/b.txt", "", map[string]string{"Lock-Token": token})
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	res = do(t, srv, http.MethodDelete, "/dir", "", nil)
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+	res = do(t, srv, http.MethodGet, "/dir/b.txt", "", nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func do(t testing.TB, srv *httptest.Server, method, p, body string, header map[string]string) *http.Response {
+	req, err := http.NewRequest(method, srv.URL+p, strings.NewReader(body))
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	res, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func readBody(t testing.TB, res *http.Response) string {
+	data, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,355 @@
+//go:build linux
+
+// Package webfsfuse mounts a WebFS filesystem with FUSE.
+package webfsfuse
+
+import (
+	"context"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+)
+
+// cacheTimeout is how long the kernel caches entries and attributes.
+// It is short because volumes can be changed by other writers.
+const cacheTimeout = time.Second
+
+// renameNoReplace is the RENAME_NOREPLACE flag to renameat2.
+const renameNoReplace = 0x1
+
+var (
+	_ fs.NodeLookuper  = &node{}
+	_ fs.NodeReaddirer = &node{}
+	_ fs.NodeGetattrer = &node{}
+	_ fs.NodeSetattrer = &node{}
+	_ fs.NodeOpener    = &node{}
+	_ fs.NodeCreater   = &node{}
+	_ fs.NodeMkdirer   = &node{}
+	_ fs.NodeUnlinker  = &node{}
+	_ fs.NodeRmdirer   = &node{}
+	_ fs.NodeRenamer   = &node{}
+
+	_ fs.FileReader   = &handle{}
+	_ fs.FileWriter   = &handle{}
+	_ fs.FileFlusher  = &handle{}
+	_ fs.FileFsyncer  = &handle{}
+	_ fs.FileReleaser = &handle{}
+)
+
+// Mount mounts wfs at dir, and returns the server handling requests for it.
+// The caller must call Unmount on the server, and then Wait for it to finish.
+//
+// Operations on files are done with ctx, rather than the context of each request,
+// since open files outlive the requests which opened them.
+// Volumes configured within wfs appear as directories, alongside their .webfs config files.
+func Mount(ctx context.Context, wfs *webfs.FS, dir string) (*fuse.Server, error) {
+	timeout := cacheTimeout
+	root := &node{wfs: wfs, ctx: ctx}
+	return fs.Mount(dir, root, &fs.Options{
+		MountOptions: fuse.MountOptions{
+			FsName: "webfs",
+			Name:   "webfs",
+			// mount without fusermount when running as root, falling back to fusermount otherwise.
+			DirectMount: true,
+		},
+		EntryTimeout: &timeout,
+		AttrTimeout:  &timeout,
+		UID:          uint32(os.Getuid()),
+		GID:          uint32(os.Getgid()),
+	})
+}
+
+// node is a file or directory, it is identified by its path which go-fuse keeps up to date across renames.
+type node struct {
+	fs.Inode
+	wfs *webfs.FS
+	ctx context.Context
+}
+
+func (n *node) path() string {
+	return n.Path(nil)
+}
+
+func (n *node) child(name string) string {
+	return path.Join(n.path(), name)
+}
+
+func (n *node) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	info, err := n.wfs.Stat(ctx, n.child(name))
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	return n.newChild(ctx, info, &out.Attr), 0
+}
+
+func (n *node) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	var ents []iofs.DirEntry
+	if err := n.wfs.Ls(ctx, n.path(), func(ent iofs.DirEntry) error {
+		ents = append(ents, ent)
+		return nil
+	}); err != nil {
+		return nil, toErrno(err)
+	}
+	list := make([]fuse.DirEntry, 0, len(ents))
+	for _, ent := range ents {
+		list = append(list, fuse.DirEntry{Name: ent.Name(), Mode: modeToFuse(ent.Type())})
+	}
+	return fs.NewListDirStream(list), 0
+}
+
+func (n *node) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	var info iofs.FileInfo
+	var err error
+	if h, ok := fh.(*handle); ok {
+		info, err = h.stat()
+	} else {
+		info, err = n.wfs.Stat(ctx, n.path())
+	}
+	if err != nil {
+		return toErrno(err)
+	}
+	setAttr(info, &out.Attr)
+	return 0
+}
+
+func (n *node) Setattr(ctx context.Context, fh fs.FileHandle, in *fuse.SetAttrIn, out *fuse.AttrOut) syscall.Errno {
+	p := n.path()
+	if size, ok := in.GetSize(); ok {
+		if err := n.truncate(fh, int64(size)); err != nil {
+			return toErrno(err)
+		}
+	}
+	if mode, ok := in.GetMode(); ok {
+		if err := n.wfs.Txn(n.ctx, func(tx *webfs.Tx) error {
+			return tx.Chmod(p, iofs.FileMode(mode)&iofs.ModePerm)
+		}); err != nil {
+			return toErrno(err)
+		}
+	}
+	if mtime, ok := in.GetMTime(); ok {
+		if err := n.wfs.Chtimes(n.ctx, p, mtime); err != nil {
+			return toErrno(err)
+		}
+	}
+	return n.Getattr(ctx, fh, out)
+}
+
+// truncate changes the size of the file, through fh if it is open for writing.
+func (n *node) truncate(fh fs.FileHandle, size int64) error {
+	if h, ok := fh.(*handle); ok && h.writable {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.f.Truncate(size)
+	}
+	f, err := n.wfs.OpenFile(n.ctx, n.path(), os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (n *node) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	h, err := n.open(n.path(), int(flags), 0)
+	if err != nil {
+		return nil, 0, toErrno(err)
+	}
+	return h, 0, 0
+}
+
+func (n *node) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	p := n.child(name)
+	h, err := n.open(p, int(flags)|os.O_CREATE, iofs.FileMode(mode)&iofs.ModePerm)
+	if err != nil {
+		return nil, nil, 0, toErrno(err)
+	}
+	info, err := h.stat()
+	if err != nil {
+		h.f.Close()
+		return nil, nil, 0, toErrno(err)
+	}
+	return n.newChild(ctx, info, &out.Attr), h, 0, 0
+}
+
+func (n *node) open(p string, flags int, perm iofs.FileMode) (*handle, error) {
+	// the kernel provides the offset of every write, including appends.
+	flags &^= os.O_APPEND
+	f, err := n.wfs.OpenFile(n.ctx, p, flags, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &handle{f: f, writable: flags&(os.O_WRONLY|os.O_RDWR) != 0}, nil
+}
+
+func (n *node) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	p := n.child(name)
+	if _, err := n.wfs.Stat(ctx, p); err == nil {
+		return nil, syscall.EEXIST
+	}
+	if err := n.wfs.Mkdir(ctx, p); err != nil {
+		return nil, toErrno(err)
+	}
+	info, err := n.wfs.Stat(ctx, p)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	return n.newChild(ctx, info, &out.Attr), 0
+}
+
+func (n *node) Unlink(ctx context.Context, name string) syscall.Errno {
+	p := n.child(name)
+	info, err := n.wfs.Stat(ctx, p)
+	if err != nil {
+		return toErrno(err)
+	}
+	if info.IsDir() {
+		return syscall.EISDIR
+	}
+	return toErrno(n.wfs.Remove(ctx, p))
+}
+
+func (n *node) Rmdir(ctx context.Context, name string) syscall.Errno {
+	p := n.child(name)
+	info, err := n.wfs.Stat(ctx, p)
+	if err != nil {
+		return toErrno(err)
+	}
+	if !info.IsDir() {
+		return syscall.ENOTDIR
+	}
+	empty := true
+	if err := n.wfs.Ls(ctx, p, func(iofs.DirEntry) error {
+		empty = false
+		return nil
+	}); err != nil {
+		return toErrno(err)
+	}
+	if !empty {
+		return syscall.ENOTEMPTY
+	}
+	return toErrno(n.wfs.Remove(ctx, p))
+}
+
+func (n *node) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	src := n.child(name)
+	dst := path.Join(newParent.EmbeddedInode().Path(nil), newName)
+	switch flags {
+	case 0:
+	case renameNoReplace:
+		if _, err := n.wfs.Stat(ctx, dst); err == nil {
+			return syscall.EEXIST
+		}
+	default:
+		return syscall.ENOTSUP
+	}
+	return toErrno(n.wfs.Rename(ctx, src, dst))
+}
+
+func (n *node) newChild(ctx context.Context, info iofs.FileInfo, out *fuse.Attr) *fs.Inode {
+	setAttr(info, out)
+	child := &node{wfs: n.wfs, ctx: n.ctx}
+	return n.NewInode(ctx, child, fs.StableAttr{Mode: modeToFuse(info.Mode())})
+}
+
+// handle is an open file.
+type handle struct {
+	// mu protects f, which is not safe for concurrent use.
+	mu       sync.Mutex
+	f        *webfs.File
+	writable bool
+}
+
+func (h *handle) stat() (iofs.FileInfo, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.f.Stat()
+}
+
+func (h *handle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, err := h.f.ReadAtContext(ctx, dest, off)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, toErrno(err)
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (h *handle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, err := h.f.WriteAt(data, off)
+	if err != nil {
+		return 0, toErrno(err)
+	}
+	return uint32(n), 0
+}
+
+// Flush commits the writes made through the handle, it is called whenever a file descriptor is closed.
+func (h *handle) Flush(ctx context.Context) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return toErrno(h.f.Sync())
+}
+
+func (h *handle) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	return h.Flush(ctx)
+}
+
+func (h *handle) Release(ctx context.Context) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return toErrno(h.f.Close())
+}
+
+func setAttr(info iofs.FileInfo, out *fuse.Attr) {
+	out.Mode = modeToFuse(info.Mode()) | uint32(info.Mode().Perm())
+	out.Size = uint64(info.Size())
+	out.Blocks = (out.Size + 511) / 512
+	out.Nlink = 1
+	mtime := info.ModTime()
+	out.SetTimes(&mtime, &mtime, &mtime)
+}
+
+func modeToFuse(mode iofs.FileMode) uint32 {
+	if mode.IsDir() {
+		return fuse.S_IFDIR
+	}
+	return fuse.S_IFREG
+}
+
+func toErrno(err error) syscall.Errno {
+	var errno syscall.Errno
+	var roErr webfs.ErrReadOnly
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &errno):
+		return errno
+	case errors.Is(err, iofs.ErrNotExist):
+		return syscall.ENOENT
+	case errors.Is(err, iofs.ErrExist):
+		return syscall.EEXIST
+	case errors.Is(err, iofs.ErrPermission):
+		return syscall.EACCES
+	case errors.As(err, &roErr):
+		return syscall.EROFS
+	case errors.Is(err, context.Canceled):
+		return syscall.EINTR
+	default:
+		return syscall.EIO
+	}
+}
@@ -0,0 +1,93 @@
+//go:build linux
+
+package webfsfuse
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+	"github.com/brendoncarroll/webfs/pkg/webfstest"
+)
+
+func TestMount(t *testing.T) {
+	ctx := context.Background()
+	wfs := webfstest.NewMemFS(t)
+	dir := mountTest(t, wfs)
+
+	// create, write and read back.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0o644))
+	data, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+	require.Equal(t, "hello", webfstest.Cat(t, wfs, "a.txt"))
+
+	// overwrite and append.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hi"), 0o644))
+	f, err := os.OpenFile(filepath.Join(dir, "a.txt"), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte(" there"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "hi there", webfstest.Cat(t, wfs, "a.txt"))
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	require.ErrorIs(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755), os.ErrExist)
+	require.NoError(t, os.Rename(filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub", "b.txt")))
+	require.Equal(t, "hi there", webfstest.Cat(t, wfs, "sub/b.txt"))
+	ents, err := os.ReadDir(filepath.Join(dir, "sub"))
+	require.NoError(t, err)
+	require.Len(t, ents, 1)
+	require.Equal(t, "b.txt", ents[0].Name())
+
+	require.Error(t, os.Remove(filepath.Join(dir, "sub")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "sub", "b.txt")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "sub")))
+	_, err = wfs.Stat(ctx, "sub")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestMountNested(t *testing.T) {
+	ctx := context.Background()
+	wfs := webfstest.NewMemFS(t)
+	webfstest.PutMemVolume(t, wfs, "vol")
+	require.NoError(t, wfs.PutFile(ctx, "vol/x.txt", strings.NewReader("x")))
+	dir := mountTest(t, wfs)
+
+	ents, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, ent := range ents {
+		names = append(names, ent.Name())
+		if ent.Name() == "vol" {
+			require.True(t, ent.IsDir())
+		}
+	}
+	require.ElementsMatch(t, []string{"vol", "vol.webfs"}, names)
+	data, err := os.ReadFile(filepath.Join(dir, "vol", "x.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "x", string(data))
+}
+
+// mountTest mounts wfs in a temporary directory, and skips the test if FUSE is not available.
+func mountTest(t *testing.T, wfs *webfs.FS) string {
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		t.Skip("fuse is not available:", err)
+	}
+	dir := t.TempDir()
+	server, err := Mount(context.Background(), wfs, dir)
+	if err != nil {
+		t.Skip("cannot mount:", err)
+	}
+	t.Cleanup(func() {
+		require.NoError(t, server.Unmount())
+		server.Wait()
+	})
+	require.NoError(t, server.WaitMount())
+	return dir
+}
@@ -0,0 +1,230 @@
+// Package webfshttp serves a WebFS filesystem over HTTP.
+package webfshttp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	iofs "io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+)
+
+// Handler serves the files in a WebFS filesystem, the URL path is the path in the filesystem.
+//
+//	GET    /<path>  responds with the file, or a listing if it is a directory
+//	HEAD   /<path>  like GET, without the body
+//	PUT    /<path>  creates or replaces the file with the body
+//	DELETE /<path>  removes the file or directory, and everything in it
+//	POST   /<path>  creates the directory, and any of its missing parents
+//
+// GET supports range requests and conditional requests, the ETag of a file is derived from the hashes of its contents.
+// Directory listings are HTML, or JSON if the request accepts application/json.
+type Handler struct {
+	fs *webfs.FS
+}
+
+// NewHandler returns a Handler serving fs.
+func NewHandler(fs *webfs.FS) *Handler {
+	return &Handler{fs: fs}
+}
+
+// DirEntry is an entry in a JSON directory listing.
+type DirEntry struct {
+	Name    string    `json:"name"`
+	IsDir   bool      `json:"is_dir"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.Trim(path.Clean("/"+r.URL.Path), "/")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.get(w, r, p)
+	case http.MethodPut:
+		h.put(w, r, p)
+	case http.MethodDelete:
+		h.delete(w, r, p)
+	case http.MethodPost:
+		h.mkdir(w, r, p)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, p string) {
+	// files are opened with the request's context, so reads stop when the client disconnects.
+	f, err := h.fs.Open(r.Context(), p)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if info.IsDir() {
+		h.list(w, r, f)
+		return
+	}
+	id, err := f.ContentID()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+hex.EncodeToString(id)+`"`)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, f *webfs.File) {
+	ents, err := f.ReadDir(0)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if acceptsJSON(r) {
+		listing := make([]DirEntry, 0, len(ents))
+		for _, ent := range ents {
+			info, err := ent.Info()
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			listing = append(listing, DirEntry{
+				Name:    ent.Name(),
+				IsDir:   ent.IsDir(),
+				Size:    info.Size(),
+				Mode:    info.Mode().String(),
+				ModTime: info.ModTime(),
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodHead {
+			json.NewEncoder(w).Encode(listing)
+		}
+		return
+	}
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		// links in the listing are relative to the directory.
+		http.Redirect(w, r, path.Base(r.URL.Path)+"/", http.StatusMovedPermanently)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	fmt.Fprintln(w, "<pre>")
+	for _, ent := range ents {
+		name := ent.Name()
+		if ent.IsDir() {
+			name += "/"
+		}
+		u := url.URL{Path: name}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", u.String(), html.EscapeString(name))
+	}
+	fmt.Fprintln(w, "</pre>")
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, p string) {
+	if p == "" {
+		http.Error(w, "cannot replace the root", http.StatusBadRequest)
+		return
+	}
+	info, err := h.fs.Stat(r.Context(), p)
+	switch {
+	case err == nil && info.IsDir():
+		// replacing the directory would remove everything in it.
+		http.Error(w, fmt.Sprintf("%q is a directory", p), http.StatusConflict)
+		return
+	case err != nil && !errors.Is(err, iofs.ErrNotExist):
+		writeError(w, err)
+		return
+	}
+	existed := err == nil
+	if err := h.fs.PutFile(r.Context(), p, r.Body); err != nil {
+		writeError(w, err)
+		return
+	}
+	if existed {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, p string) {
+	if p == "" {
+		http.Error(w, "cannot remove the root", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.fs.Stat(r.Context(), p); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.fs.Remove(r.Context(), p); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) mkdir(w http.ResponseWriter, r *http.Request, p string) {
+	info, err := h.fs.Stat(r.Context(), p)
+	switch {
+	case err == nil && !info.IsDir():
+		http.Error(w, fmt.Sprintf("%q is a file", p), http.StatusConflict)
+		return
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case !errors.Is(err, iofs.ErrNotExist):
+		writeError(w, err)
+		return
+	}
+	if err := h.fs.Mkdir(r.Context(), p); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func acceptsJSON(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept") {
+		for _, mt := range strings.Split(v, ",") {
+			mt, _, _ = strings.Cut(mt, ";")
+			if strings.TrimSpace(mt) == "application/json" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var roErr webfs.ErrReadOnly
+	var conflictErr webfs.ErrConflict
+	switch {
+	case errors.Is(err, iofs.ErrNotExist):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, iofs.ErrExist):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, iofs.ErrPermission), errors.As(err, &roErr):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.As(err, &conflictErr):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
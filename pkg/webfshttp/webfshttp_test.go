@@ -0,0 +1,124 @@
+package webfshttp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+	"github.com/brendoncarroll/webfs/pkg/webfstest"
+)
+
+func TestPutGet(t *testing.T) {
+	_, srv := newTestServer(t)
+	res := do(t, srv, http.MethodPut, "/dir/a.txt", strings.NewReader("hello world"), nil)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	res = do(t, srv, http.MethodPut, "/dir/a.txt", strings.NewReader("0123456789"), nil)
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	res = do(t, srv, http.MethodGet, "/dir/a.txt", nil, nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "0123456789", readBody(t, res))
+	etag := res.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	res = do(t, srv, http.MethodGet, "/dir/a.txt", nil, map[string]string{"Range": "bytes=2-4"})
+	require.Equal(t, http.StatusPartialContent, res.StatusCode)
+	require.Equal(t, "234", readBody(t, res))
+
+	res = do(t, srv, http.MethodGet, "/dir/a.txt", nil, map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusNotModified, res.StatusCode)
+
+	res = do(t, srv, http.MethodHead, "/dir/a.txt", nil, nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "10", res.Header.Get("Content-Length"))
+	require.Equal(t, etag, res.Header.Get("ETag"))
+
+	// the ETag changes with the contents.
+	do(t, srv, http.MethodPut, "/dir/a.txt", strings.NewReader("something else"), nil)
+	res = do(t, srv, http.MethodGet, "/dir/a.txt", nil, map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NotEqual(t, etag, res.Header.Get("ETag"))
+
+	res = do(t, srv, http.MethodGet, "/missing.txt", nil, nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestMkdirDelete(t *testing.T) {
+	ctx := context.Background()
+	fs, srv := newTestServer(t)
+	res := do(t, srv, http.MethodPost, "/a/b", nil, nil)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	info, err := fs.Stat(ctx, "a/b")
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	res = do(t, srv, http.MethodPost, "/a/b", nil, nil)
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	require.NoError(t, fs.PutFile(ctx, "a/b/c.txt", strings.NewReader("c")))
+	res = do(t, srv, http.MethodPut, "/a/b", strings.NewReader("b"), nil)
+	require.Equal(t, http.StatusConflict, res.StatusCode)
+	_, err = fs.Stat(ctx, "a/b/c.txt")
+	require.NoError(t, err)
+	res = do(t, srv, http.MethodDelete, "/a", nil, nil)
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+	_, err = fs.Stat(ctx, "a")
+	require.Error(t, err)
+	res = do(t, srv, http.MethodDelete, "/a", nil, nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	fs, srv := newTestServer(t)
+	require.NoError(t, fs.PutFile(ctx, "dir/a.txt", strings.NewReader("abc")))
+	require.NoError(t, fs.Mkdir(ctx, "dir/sub"))
+
+	res := do(t, srv, http.MethodGet, "/dir", nil, map[string]string{"Accept": "application/json"})
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var ents []DirEntry
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&ents))
+	require.Len(t, ents, 2)
+	require.Equal(t, "a.txt", ents[0].Name)
+	require.Equal(t, int64(3), ents[0].Size)
+	require.False(t, ents[0].IsDir)
+	require.Equal(t, "sub", ents[1].Name)
+	require.True(t, ents[1].IsDir)
+
+	res = do(t, srv, http.MethodGet, "/dir/", nil, nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	body := readBody(t, res)
+	require.Contains(t, body, `<a href="a.txt">a.txt</a>`)
+	require.Contains(t, body, `<a href="sub/">sub/</a>`)
+}
+
+func newTestServer(t testing.TB) (*webfs.FS, *httptest.Server) {
+	fs := webfstest.NewMemFS(t)
+	srv := httptest.NewServer(NewHandler(fs))
+	t.Cleanup(srv.Close)
+	return fs, srv
+}
+
+func do(t testing.TB, srv *httptest.Server, method, p string, body io.Reader, header map[string]string) *http.Response {
+	req, err := http.NewRequest(method, srv.URL+p, body)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	res, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { res.Body.Close() })
+	return res
+}
+
+func readBody(t testing.TB, res *http.Response) string {
+	data, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(data)
+}
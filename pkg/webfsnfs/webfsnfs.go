@@ -0,0 +1,392 @@
+// Package webfsnfs serves a WebFS filesystem over NFSv3.
+package webfsnfs
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	billy "github.com/go-git/go-billy/v5"
+	nfs "github.com/willscott/go-nfs"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+)
+
+var (
+	_ nfs.Handler      = &Handler{}
+	_ billy.Filesystem = &fileSystem{}
+	_ billy.Change     = &fileSystem{}
+	_ billy.File       = &file{}
+)
+
+const (
+	// maxHandleSize is the largest file handle allowed by NFSv3.
+	maxHandleSize = 64
+	// fingerprintSize is the number of bytes of the volume fingerprint at the start of each handle.
+	fingerprintSize = 8
+
+	handlePath = 0x00
+	handleHash = 0x01
+
+	// hashSize is the number of bytes of the hash of the path in a hashed handle.
+	hashSize = 16
+	// maxPrefixSize is the length of the longest directory which fits in a hashed handle,
+	// after the fingerprint, type, length of the path, and hash of the path.
+	maxPrefixSize = maxHandleSize - fingerprintSize - 1 - 2 - hashSize
+	// handleLimit is the number of hashed handles which are cached.
+	handleLimit = 1024
+)
+
+// Serve serves fs over NFSv3 on l, until l is closed.
+// The mount protocol is served on the same listener.
+func Serve(ctx context.Context, l net.Listener, fs *webfs.FS) error {
+	return nfs.Serve(l, NewHandler(ctx, fs))
+}
+
+// Handler implements nfs.Handler for a webfs.FS.
+//
+// File handles are derived from the fingerprint of the root volume and the path of the file,
+// so they stay valid across restarts of the server, and for as long as the file is not moved.
+// A path which is too long to fit in a handle is hashed instead, and the handle holds the hash,
+// the length of the path and the longest of its parent directories which fits.
+// The paths of the most recently used hashed handles are cached, others are found by searching
+// the parent directory for a path with the same length and hash.
+type Handler struct {
+	fsys *fileSystem
+	fp   [fingerprintSize]byte
+
+	mu     sync.Mutex
+	hashed map[[hashSize]byte]*list.Element
+	// recent holds the cached paths of hashed handles, most recently used first.
+	recent list.List
+}
+
+// NewHandler returns a Handler for fs.
+// ctx is used for all of the operations on fs.
+func NewHandler(ctx context.Context, fs *webfs.FS) *Handler {
+	h := &Handler{
+		fsys:   &fileSystem{ctx: ctx, fs: fs},
+		hashed: map[[hashSize]byte]*list.Element{},
+	}
+	fp := fs.Fingerprint()
+	copy(h.fp[:], fp[:])
+	return h
+}
+
+func (h *Handler) Mount(ctx context.Context, conn net.Conn, req nfs.MountRequest) (nfs.MountStatus, billy.Filesystem, []nfs.AuthFlavor) {
+	return nfs.MountStatusOk, h.fsys, []nfs.AuthFlavor{nfs.AuthFlavorNull}
+}
+
+func (h *Handler) Change(fs billy.Filesystem) billy.Change {
+	return h.fsys
+}
+
+func (h *Handler) FSStat(ctx context.Context, fs billy.Filesystem, stat *nfs.FSStat) error {
+	return nil
+}
+
+// ToHandle returns the handle for the file at p.
+func (h *Handler) ToHandle(fs billy.Filesystem, p []string) []byte {
+	name := strings.Join(p, "/")
+	handle := make([]byte, 0, maxHandleSize)
+	handle = append(handle, h.fp[:]...)
+	if len(handle)+1+len(name) <= maxHandleSize {
+		handle = append(handle, handlePath)
+		return append(handle, name...)
+	}
+	sum := hashPath(name)
+	h.cache(sum, name)
+	prefix := path.Dir(name)
+	for len(prefix) > maxPrefixSize {
+		prefix = path.Dir(prefix)
+	}
+	if prefix == "." {
+		prefix = ""
+	}
+	handle = append(handle, handleHash)
+	handle = binary.BigEndian.AppendUint16(handle, uint16(len(name)))
+	handle = append(handle, sum[:]...)
+	return append(handle, prefix...)
+}
+
+// FromHandle returns the path for a handle created by ToHandle.
+func (h *Handler) FromHandle(handle []byte) (billy.Filesystem, []string, error) {
+	if len(handle) < fingerprintSize+1 {
+		return nil, nil, fmt.Errorf("handle is too short")
+	}
+	if !bytes.Equal(handle[:fingerprintSize], h.fp[:]) {
+		return nil, nil, fmt.Errorf("handle is for a different volume")
+	}
+	var name string
+	switch data := handle[fingerprintSize+1:]; handle[fingerprintSize] {
+	case handlePath:
+		name = string(data)
+	case handleHash:
+		var sum [hashSize]byte
+		if len(data) < 2+len(sum) {
+			return nil, nil, fmt.Errorf("invalid hashed handle")
+		}
+		n := int(binary.BigEndian.Uint16(data))
+		copy(sum[:], data[2:])
+		prefix := string(data[2+len(sum):])
+		p, err := h.lookup(sum, n, prefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		name = p
+	default:
+		return nil, nil, fmt.Errorf("unknown handle type %d", handle[fingerprintSize])
+	}
+	if name == "" {
+		return h.fsys, []string{}, nil
+	}
+	return h.fsys, strings.Split(name, "/"), nil
+}
+
+// InvalidateHandle forgets handle, if it is a hashed handle.
+// Handles containing a path do not need to be invalidated.
+func (h *Handler) InvalidateHandle(fs billy.Filesystem, handle []byte) error {
+	if len(handle) >= fingerprintSize+1+2+hashSize && handle[fingerprintSize] == handleHash {
+		var sum [hashSize]byte
+		copy(sum[:], handle[fingerprintSize+1+2:])
+		h.mu.Lock()
+		if e, exists := h.hashed[sum]; exists {
+			h.recent.Remove(e)
+			delete(h.hashed, sum)
+		}
+		h.mu.Unlock()
+	}
+	return nil
+}
+
+// HandleLimit returns the number of hashed handles which are cached.
+// Handles which are not cached are still valid, but they are slower to resolve.
+func (h *Handler) HandleLimit() int {
+	return handleLimit
+}
+
+// hashedPath is the path cached for a hashed handle.
+type hashedPath struct {
+	sum  [hashSize]byte
+	name string
+}
+
+// cache records that sum is the hash of name, evicting the least recently used path if the cache is full.
+func (h *Handler) cache(sum [hashSize]byte, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, exists := h.hashed[sum]; exists {
+		h.recent.MoveToFront(e)
+		return
+	}
+	h.hashed[sum] = h.recent.PushFront(hashedPath{sum: sum, name: name})
+	if h.recent.Len() > handleLimit {
+		e := h.recent.Back()
+		h.recent.Remove(e)
+		delete(h.hashed, e.Value.(hashedPath).sum)
+	}
+}
+
+// lookup returns the path of length n with hash sum, searching within the directory prefix if it is not cached.
+func (h *Handler) lookup(sum [hashSize]byte, n int, prefix string) (string, error) {
+	h.mu.Lock()
+	e, exists := h.hashed[sum]
+	if exists {
+		h.recent.MoveToFront(e)
+	}
+	h.mu.Unlock()
+	if exists {
+		return e.Value.(hashedPath).name, nil
+	}
+	name, err := h.find(prefix, sum, n)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", fmt.Errorf("unknown hashed handle")
+	}
+	h.cache(sum, name)
+	return name, nil
+}
+
+// find searches dir for the path of length n with hash sum, it returns "" if there is not one.
+// Only directories with paths shorter than n are searched.
+func (h *Handler) find(dir string, sum [hashSize]byte, n int) (string, error) {
+	var found string
+	errFound := errors.New("found")
+	err := h.fsys.fs.Ls(h.fsys.ctx, dir, func(ent iofs.DirEntry) error {
+		p := path.Join(dir, ent.Name())
+		switch {
+		case len(p) == n && hashPath(p) == sum:
+			found = p
+			return errFound
+		case len(p) < n && ent.IsDir():
+			var err error
+			if found, err = h.find(p, sum, n); err != nil {
+				return err
+			} else if found != "" {
+				return errFound
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errFound) {
+		return "", err
+	}
+	return found, nil
+}
+
+func hashPath(p string) (ret [hashSize]byte) {
+	sum := sha256.Sum256([]byte(p))
+	copy(ret[:], sum[:])
+	return ret
+}
+
+// fileSystem implements billy.Filesystem and billy.Change on top of a webfs.FS.
+type fileSystem struct {
+	ctx context.Context
+	fs  *webfs.FS
+}
+
+func (fsys *fileSystem) Create(name string) (billy.File, error) {
+	return fsys.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+func (fsys *fileSystem) Open(name string) (billy.File, error) {
+	return fsys.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the file at name.
+// Writes are committed when the file is closed, which the NFS server does after every write.
+func (fsys *fileSystem) OpenFile(name string, flag int, perm os.FileMode) (billy.File, error) {
+	f, err := fsys.fs.OpenFile(fsys.ctx, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, name: name}, nil
+}
+
+func (fsys *fileSystem) Stat(name string) (os.FileInfo, error) {
+	return fsys.fs.Stat(fsys.ctx, name)
+}
+
+func (fsys *fileSystem) Rename(oldName, newName string) error {
+	return fsys.fs.Rename(fsys.ctx, oldName, newName)
+}
+
+// Remove removes the file or empty directory at name.
+func (fsys *fileSystem) Remove(name string) error {
+	info, err := fsys.fs.Stat(fsys.ctx, name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		errNotEmpty := errors.New("not empty")
+		if err := fsys.fs.Ls(fsys.ctx, name, func(iofs.DirEntry) error {
+			return errNotEmpty
+		}); errors.Is(err, errNotEmpty) {
+			return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		} else if err != nil {
+			return err
+		}
+	}
+	return fsys.fs.Remove(fsys.ctx, name)
+}
+
+func (fsys *fileSystem) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (fsys *fileSystem) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrNotSupported
+}
+
+// ReadDir lists the directory at name.
+func (fsys *fileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	if err := fsys.fs.Ls(fsys.ctx, name, func(ent iofs.DirEntry) error {
+		info, err := ent.Info()
+		if err != nil {
+			return err
+		}
+		infos = append(infos, info)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+func (fsys *fileSystem) MkdirAll(name string, perm os.FileMode) error {
+	return fsys.fs.Mkdir(fsys.ctx, name)
+}
+
+// Lstat is the same as Stat, WebFS does not have symlinks.
+func (fsys *fileSystem) Lstat(name string) (os.FileInfo, error) {
+	return fsys.Stat(name)
+}
+
+func (fsys *fileSystem) Symlink(target, link string) error {
+	return billy.ErrNotSupported
+}
+
+func (fsys *fileSystem) Readlink(link string) (string, error) {
+	return "", billy.ErrNotSupported
+}
+
+func (fsys *fileSystem) Chroot(p string) (billy.Filesystem, error) {
+	return nil, billy.ErrNotSupported
+}
+
+func (fsys *fileSystem) Root() string {
+	return "/"
+}
+
+func (fsys *fileSystem) Chmod(name string, mode os.FileMode) error {
+	return fsys.fs.Txn(fsys.ctx, func(tx *webfs.Tx) error {
+		return tx.Chmod(name, mode&iofs.ModePerm)
+	})
+}
+
+func (fsys *fileSystem) Lchown(name string, uid, gid int) error {
+	return billy.ErrNotSupported
+}
+
+func (fsys *fileSystem) Chown(name string, uid, gid int) error {
+	return billy.ErrNotSupported
+}
+
+// Chtimes sets the modification time of name, WebFS does not store access times.
+func (fsys *fileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	return fsys.fs.Chtimes(fsys.ctx, name, mtime)
+}
+
+type file struct {
+	*webfs.File
+	name string
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+// Lock does nothing, NFSv3 locking is a separate protocol which is not served.
+func (f *file) Lock() error {
+	return nil
+}
+
+func (f *file) Unlock() error {
+	return nil
+}
@@ -0,0 +1,149 @@
+package webfsnfs
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	nfsc "github.com/willscott/go-nfs-client/nfs"
+	"github.com/willscott/go-nfs-client/nfs/rpc"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+	"github.com/brendoncarroll/webfs/pkg/webfstest"
+)
+
+func TestServe(t *testing.T) {
+	ctx := context.Background()
+	wfs := webfstest.NewMemFS(t)
+	target := mountTest(t, wfs)
+
+	_, err := target.Create("a.txt", 0o644)
+	require.NoError(t, err)
+	f, err := target.OpenFile("a.txt", 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("hello world"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "hello world", webfstest.Cat(t, wfs, "a.txt"))
+
+	f, err = target.Open("a.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "hello world", string(data))
+
+	_, err = target.Mkdir("sub", 0o755)
+	require.NoError(t, err)
+	require.NoError(t, target.Rename("a.txt", "sub/b.txt"))
+	require.Equal(t, "hello world", webfstest.Cat(t, wfs, "sub/b.txt"))
+	require.Equal(t, []string{"sub"}, listDir(t, target, "."))
+	require.Equal(t, []string{"b.txt"}, listDir(t, target, "sub"))
+
+	require.Error(t, target.RmDir("sub"))
+	require.NoError(t, target.Remove("sub/b.txt"))
+	require.NoError(t, target.RmDir("sub"))
+	_, err = wfs.Stat(ctx, "sub")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestServeNested(t *testing.T) {
+	ctx := context.Background()
+	wfs := webfstest.NewMemFS(t)
+	webfstest.PutMemVolume(t, wfs, "vol")
+	require.NoError(t, wfs.PutFile(ctx, "vol/x.txt", strings.NewReader("x")))
+	target := mountTest(t, wfs)
+
+	require.Equal(t, []string{"vol", "vol.webfs"}, listDir(t, target, "."))
+	f, err := target.Open("vol/x.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "x", string(data))
+}
+
+func TestHandles(t *testing.T) {
+	ctx := context.Background()
+	wfs := webfstest.NewMemFS(t)
+	for _, p := range []string{
+		"dir/sub/b.txt",
+		strings.Repeat("x", 100) + "/long.txt",
+		"dir/" + strings.Repeat("y", 30) + "/" + strings.Repeat("z", 30) + "/long.txt",
+	} {
+		require.NoError(t, wfs.PutFile(ctx, p, strings.NewReader(p)))
+	}
+	h1 := NewHandler(ctx, wfs)
+	h2 := NewHandler(ctx, wfs)
+	for _, p := range [][]string{
+		{},
+		{"a.txt"},
+		{"dir", "sub", "b.txt"},
+		{strings.Repeat("x", 100), "long.txt"},
+		{"dir", strings.Repeat("y", 30), strings.Repeat("z", 30), "long.txt"},
+	} {
+		handle := h1.ToHandle(h1.fsys, p)
+		require.LessOrEqual(t, len(handle), maxHandleSize)
+		_, actual, err := h1.FromHandle(handle)
+		require.NoError(t, err)
+		require.Equal(t, p, actual)
+		// handles do not depend on the state of the handler, so they survive a restart.
+		require.Equal(t, handle, NewHandler(ctx, wfs).ToHandle(h1.fsys, p))
+		_, actual, err = h2.FromHandle(handle)
+		require.NoError(t, err)
+		require.Equal(t, p, actual)
+	}
+
+	// hashed handles for paths which do not exist cannot be resolved by another handler.
+	missing := h1.ToHandle(h1.fsys, []string{strings.Repeat("m", 100)})
+	_, _, err := h2.FromHandle(missing)
+	require.Error(t, err)
+
+	// only the most recent hashed handles are cached.
+	for i := 0; i < 2*handleLimit; i++ {
+		h1.ToHandle(h1.fsys, []string{strings.Repeat("n", 100), strconv.Itoa(i)})
+	}
+	require.Len(t, h1.hashed, handleLimit)
+	require.Equal(t, handleLimit, h1.recent.Len())
+
+	// handles from a different volume are rejected.
+	h3 := NewHandler(ctx, webfstest.NewFS(t, webfstest.MemSpec("other")))
+	_, _, err = h3.FromHandle(h1.ToHandle(h1.fsys, []string{"a.txt"}))
+	require.Error(t, err)
+}
+
+// mountTest serves wfs on a local port, and returns an NFS client mounted at its root.
+func mountTest(t *testing.T, wfs *webfs.FS) *nfsc.Target {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go Serve(context.Background(), l, wfs)
+	t.Cleanup(func() { l.Close() })
+
+	client, err := rpc.DialTCP("tcp", l.Addr().String(), false)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	mounter := nfsc.Mount{Client: client}
+	target, err := mounter.Mount("/", rpc.AuthNull)
+	require.NoError(t, err)
+	t.Cleanup(func() { target.Close() })
+	return target
+}
+
+func listDir(t testing.TB, target *nfsc.Target, p string) []string {
+	ents, err := target.ReadDirPlus(p)
+	require.NoError(t, err)
+	var names []string
+	for _, ent := range ents {
+		if ent.Name() == "." || ent.Name() == ".." {
+			continue
+		}
+		names = append(names, ent.Name())
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,50 @@
+// Package webfstest provides helpers for testing the packages which serve a webfs.FS.
+package webfstest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/brendoncarroll/go-state/posixfs"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brendoncarroll/webfs/pkg/webfs"
+)
+
+// MemSpec returns the spec for a volume with a memory cell and store.
+// Volumes are identified by their specs, so salt distinguishes volumes which have the same spec otherwise.
+func MemSpec(salt string) webfs.VolumeSpec {
+	return webfs.VolumeSpec{
+		Cell:  webfs.CellSpec{Memory: &struct{}{}},
+		Store: webfs.StoreSpec{Memory: &struct{}{}},
+		Salt:  []byte(salt),
+	}
+}
+
+// NewFS returns an FS with the root volume described by spec, which fails the test if it cannot be created.
+func NewFS(t testing.TB, spec webfs.VolumeSpec) *webfs.FS {
+	fs, err := webfs.New(spec, webfs.WithPosixFS(posixfs.NewOSFS()))
+	require.NoError(t, err)
+	return fs
+}
+
+// NewMemFS returns an FS with an empty root volume, which is kept in memory.
+func NewMemFS(t testing.TB) *webfs.FS {
+	return NewFS(t, MemSpec(""))
+}
+
+// PutMemVolume configures a volume kept in memory, to be mounted at p.
+// The volume is salted with p, so it is different from the root volume and from volumes mounted elsewhere.
+func PutMemVolume(t testing.TB, fs *webfs.FS, p string) {
+	data, err := webfs.MarshalVolumeSpec(MemSpec(p))
+	require.NoError(t, err)
+	require.NoError(t, fs.PutFile(context.Background(), p+".webfs", strings.NewReader(string(data))))
+}
+
+// Cat returns the contents of the file at p.
+func Cat(t testing.TB, fs *webfs.FS, p string) string {
+	buf := &strings.Builder{}
+	require.NoError(t, fs.Cat(context.Background(), p, buf))
+	return buf.String()
+}